@@ -2,42 +2,24 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
 	"net"
-	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 	"vicoin/crypto"
 	"vicoin/internal/account"
 	"vicoin/internal/client"
+	"vicoin/internal/config"
 	"vicoin/internal/node"
 	"vicoin/internal/registration"
 	"vicoin/network"
 )
 
-func getExternalIP() string {
-	apiUrl := "https://api.ipify.org?format=text"
-	response, err := http.Get(apiUrl)
-	if err != nil {
-		log.Panic(err)
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Panic(err)
-		}
-	}(response.Body)
-	externalIp, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		log.Panic(err)
-	}
-	return string(externalIp)
-}
-
 func getString() string {
 	msg, err := bufio.NewReader(os.Stdin).ReadString('\n')
 	if err != nil {
@@ -94,32 +76,124 @@ func getCredentialsFromUser() (public *crypto.PublicKey, private *crypto.Private
 	return public, private
 }
 
-func createAndConfigureClient() (*client.Client, error) {
-	fmt.Println("Configuring client ...")
+// createAndConfigureNode returns the node along with the channel it passes received transactions on, and the seeds to bootstrap from.
+func createAndConfigureNode(cfg *config.Config, genesis *account.Genesis) (*node.Node, chan account.SignedTransaction, []net.Addr, error) {
 	socketToNode := make(chan interface{})
 	nodeToClient := make(chan account.SignedTransaction)
 	dialer, err := network.NewTCPDialer()
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
-	listener, err := network.NewTCPListener()
+	listener, err := network.NewTCPListener(cfg.Listen)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
-	socket := network.NewPolysocket(socketToNode, dialer, listener)
+	advertised, err := cfg.AdvertisedAddrs(listener.Addr())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	seeds, err := cfg.SeedAddrs()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	book, err := node.NewPeerBook(cfg.PeerBook)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	nodeKey, err := network.LoadNodeKey(cfg.NodeKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	noise := network.NewNoiseUpgrader(nodeKey, 10*time.Second)
+	hello := network.NewHelloUpgrader(network.NewHello(nodeKey.ID(), advertised[0], genesis.ChainID()), 10*time.Second)
+	secureDialer := network.NewUpgradedDialer(network.NewUpgradedDialer(dialer, noise), hello)
+	secureListener := network.NewUpgradedListener(network.NewUpgradedListener(listener, noise), hello)
+	connectionEvents := make(chan network.ConnectionEvent)
+	go logConnectionEvents(connectionEvents)
+	polysocket := network.NewPolysocket(socketToNode, secureDialer, secureListener)
+	polysocket.SetLimits(cfg.Limits)
+	socket := network.NewConnectionManager(polysocket, network.DefaultBackoffPolicy, connectionEvents)
 	node, err := node.NewNode(socket, socketToNode, nodeToClient)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	node.SetAdvertisedAddrs(advertised)
+	node.SetPeerBook(book)
+	polysocket.SetEvictionStrategy(node)
+	node.SetGossipConfig(cfg.Gossip)
+	node.SetNodeKey(nodeKey)
+	node.StartHeartbeat(15*time.Second, time.Minute)
+	node.StartDiscovery(10 * time.Minute)
+	return node, nodeToClient, seeds, nil
+}
+
+func createAndConfigureClient(cfg *config.Config) (*client.Client, error) {
+	fmt.Println("Configuring client ...")
+	genesis, err := cfg.LoadGenesis()
+	if err != nil {
+		return nil, err
+	}
+	node, nodeToClient, seeds, err := createAndConfigureNode(cfg, genesis)
 	if err != nil {
 		return nil, err
 	}
-	ledger := account.NewLedger()
+	fmt.Println("Chain ID: " + genesis.ChainID())
+	ledger := account.NewGenesisLedger(genesis)
 	client, err := client.NewClient(ledger, node, nodeToClient)
 	if err != nil {
 		return nil, err
 	}
+	client.StartAntiEntropy(time.Minute)
+	client.StartDivergenceDetector(5*time.Minute, cfg.Reports)
+	go func() {
+		fmt.Printf("Bootstrapped from %d peers\n", node.Bootstrap(seeds))
+		syncLedger(client)
+	}()
 	fmt.Println("Client configured ...")
 	return client, nil
 }
 
+func createAndConfigureLightClient(cfg *config.Config) (*client.LightClient, error) {
+	fmt.Println("Configuring light client ...")
+	genesis, err := cfg.LoadGenesis()
+	if err != nil {
+		return nil, err
+	}
+	node, nodeToClient, seeds, err := createAndConfigureNode(cfg, genesis)
+	if err != nil {
+		return nil, err
+	}
+	light := client.NewLightClient(node, nodeToClient, cfg.Quorum)
+	go func() {
+		fmt.Printf("Bootstrapped from %d peers\n", node.Bootstrap(seeds))
+	}()
+	fmt.Println("Light client configured ...")
+	return light, nil
+}
+
+// syncLedger catches the ledger up with the network, printing the progress.
+func syncLedger(c *client.Client) {
+	err := c.Sync(context.Background(), func(progress client.SyncProgress) {
+		fmt.Printf("Syncing ledger : %d/%d transactions from %s, %d pending funds\n", progress.Downloaded, progress.Target, progress.Peer, progress.Pending)
+	})
+	if err != nil {
+		fmt.Println("Ledger not synced : ", err)
+		return
+	}
+	fmt.Println("Ledger synced")
+}
+
+func logConnectionEvents(events chan network.ConnectionEvent) {
+	for event := range events {
+		switch event.Kind {
+		case network.Reconnected:
+			log.Printf("Reconnected to %s after %d attempt(s)", event.Addr, event.Attempts)
+		case network.GaveUp:
+			log.Printf("Gave up reconnecting to %s after %d attempt(s) : %v", event.Addr, event.Attempts, event.Err)
+		}
+	}
+}
+
 func login(client *client.Client) {
 	for {
 		fmt.Println("Provide credentials (P), or generate new (G)? :")
@@ -145,6 +219,107 @@ func login(client *client.Client) {
 	}
 }
 
+// readPartial reads a partially signed transaction from the user.
+func readPartial() *account.SignedTransaction {
+	fmt.Println("Please paste the partially signed transaction:")
+	fmt.Print(" >   ")
+	transaction, err := new(account.SignedTransaction).FromString(getString())
+	if err != nil || transaction.Multisig == nil {
+		fmt.Println("Cancelling : Invalid partially signed transaction")
+		return nil
+	}
+	return transaction
+}
+
+func printPartial(transaction *account.SignedTransaction) {
+	encoded, err := transaction.ToString()
+	if err != nil {
+		fmt.Println("Error : ", err)
+		return
+	}
+	fmt.Printf("Signed by %d of %d required keys, pass on :\n%s\n", transaction.Signers(), transaction.Multisig.Threshold, encoded)
+}
+
+// proposeMultisig creates a transfer from a multisig account, signed by the credentials if they are one of its keys.
+func proposeMultisig(client *client.Client) {
+	fmt.Println("Please enter the number of signatures required:")
+	threshold, err := strconv.Atoi(getString())
+	if err != nil {
+		fmt.Println("Error NAN")
+		return
+	}
+	keys := make([]string, 0)
+	for {
+		fmt.Println("Please enter public key of the account (Nothing when done)")
+		key := getString()
+		if key == "" {
+			break
+		}
+		keys = append(keys, key)
+	}
+	policy := account.MultisigPolicy{Threshold: threshold, Keys: keys}
+	fmt.Println("Please enter recipient account")
+	to := getString()
+	fmt.Println("Please enter amount")
+	amount, err := strconv.ParseFloat(getString(), 64)
+	if err != nil {
+		fmt.Println("Error NAN")
+		return
+	}
+	transaction, err := account.NewMultisigTransaction(account.Transaction{ID: strconv.FormatInt(time.Now().UnixNano(), 10), To: to, Amount: amount}, policy)
+	if err != nil {
+		fmt.Println("Error : ", err)
+		return
+	}
+	fmt.Println("Spending from # " + transaction.From)
+	if err := client.Sign(transaction); err != nil && !errors.Is(err, account.ErrNotASigner) {
+		fmt.Println("Error signing : ", err)
+	}
+	printPartial(transaction)
+}
+
+// transferBatch reads outputs until an empty line, paying them in a single transaction.
+func transferBatch(client *client.Client) {
+	outputs := make([]account.Output, 0)
+	for {
+		fmt.Println("Please enter recipient account and amount, separated by a space (Nothing when done)")
+		line := getString()
+		if line == "" {
+			break
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			fmt.Println("Invalid output")
+			continue
+		}
+		amount, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			fmt.Println("Error NAN")
+			continue
+		}
+		outputs = append(outputs, account.Output{To: fields[0], Amount: amount})
+	}
+	if len(outputs) == 0 {
+		fmt.Println("Transaction cancelled")
+		return
+	}
+	fee := client.EstimateBatchFee(outputs)
+	fmt.Printf("Paying %d outputs with a fee of %f\n", len(outputs), fee)
+	results, err := client.TransferBatch(outputs, fee)
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("%f to %s : %v\n", result.Amount, result.To, result.Err)
+		} else {
+			fmt.Printf("%f to %s : paid\n", result.Amount, result.To)
+		}
+	}
+	if err != nil {
+		fmt.Println("Error performing transaction : ", err)
+	} else {
+		fmt.Println("Transaction successfully performed")
+	}
+}
+
 func printHelp() {
 	fmt.Println("Valid commands: ")
 	format := "%-12s : %-12s\n"
@@ -154,6 +329,12 @@ func printHelp() {
 	fmt.Printf(format, "connect", "initiate shell interaction for establishing TCP connection")
 	fmt.Printf(format, "transfer", "initiate shell interaction for transfering funds")
 	fmt.Printf(format, "balance", "initiate shell interaction for looking up balance")
+	fmt.Printf(format, "batch", "initiate shell interaction for paying several accounts at once")
+	fmt.Printf(format, "multisig", "propose a transfer from a multisig account")
+	fmt.Printf(format, "sign", "add own signature to a partially signed transaction")
+	fmt.Printf(format, "submit", "submit a transaction signed by enough keys of its multisig account")
+	fmt.Printf(format, "issue", "initiate shell interaction for issuing funds, as a minter")
+	fmt.Printf(format, "sync", "catch the ledger up with connected peers")
 }
 
 func handleInput(input string, client *client.Client) (quit bool) {
@@ -162,6 +343,9 @@ func handleInput(input string, client *client.Client) (quit bool) {
 		printHelp()
 	case "connect":
 		address := getAddressFromUser()
+		if address == nil {
+			return false
+		}
 		err := client.Connect(address)
 		if err != nil {
 			fmt.Println("Error : ", err)
@@ -178,7 +362,49 @@ func handleInput(input string, client *client.Client) (quit bool) {
 		default:
 			balance = fmt.Sprintf("%f", client.GetBalance(account))
 		}
+		if !client.Synced() {
+			balance += " (unsynced, run sync once connected to catch up)"
+		}
 		fmt.Println("Balance : " + balance)
+	case "sync":
+		syncLedger(client)
+	case "multisig":
+		proposeMultisig(client)
+	case "sign":
+		if transaction := readPartial(); transaction != nil {
+			if err := client.Sign(transaction); err != nil {
+				fmt.Println("Error signing : ", err)
+				return false
+			}
+			printPartial(transaction)
+		}
+	case "submit":
+		if transaction := readPartial(); transaction != nil {
+			if err := client.Submit(transaction); err != nil {
+				fmt.Println("Error performing transaction : ", err)
+			} else {
+				fmt.Println("Transaction successfully performed")
+			}
+		}
+	case "batch":
+		transferBatch(client)
+	case "issue":
+		fmt.Println("Please enter recipient account (Nothing for own account)")
+		account := getString()
+		if account == "" {
+			account = client.GetAccount()
+		}
+		fmt.Println("Please enter amount (Nothing to cancel)")
+		amount, err := strconv.ParseFloat(getString(), 64)
+		if err != nil {
+			fmt.Println("Issuance cancelled")
+			return false
+		}
+		if err := client.Issue(amount, account); err != nil {
+			fmt.Println("Error performing issuance : ", err)
+		} else {
+			fmt.Println("Issuance successfully performed")
+		}
 	case "transfer":
 		fmt.Println("Please enter recipient account (Nothing to cancel)")
 		account := getString()
@@ -196,7 +422,26 @@ func handleInput(input string, client *client.Client) (quit bool) {
 				return false
 			default:
 				if floatAmount, err := strconv.ParseFloat(amount, 64); err == nil {
-					err := client.Transfer(floatAmount, account)
+					fee := client.EstimateFee(account)
+					fmt.Printf("Please enter fee (Nothing for the estimated %f)\n", fee)
+					if input := getString(); input != "" {
+						fee, err = strconv.ParseFloat(input, 64)
+						if err != nil {
+							fmt.Println("Error NAN")
+							return false
+						}
+					}
+					fmt.Println("Please enter minutes the transaction stays valid for (Nothing for ever)")
+					expiresAt := int64(0)
+					if input := getString(); input != "" {
+						minutes, err := strconv.Atoi(input)
+						if err != nil || minutes <= 0 {
+							fmt.Println("Transaction cancelled : invalid validity")
+							return false
+						}
+						expiresAt = time.Now().Add(time.Duration(minutes) * time.Minute).Unix()
+					}
+					err := client.TransferWithin(floatAmount, fee, account, 0, expiresAt)
 					if err != nil {
 						fmt.Println("Error performing transaction : ", err)
 					} else {
@@ -238,14 +483,93 @@ func handleInput(input string, client *client.Client) (quit bool) {
 	return false
 }
 
+func printLightHelp() {
+	fmt.Println("Valid commands: ")
+	format := "%-12s : %-12s\n"
+	fmt.Printf(format, "quit", "exits the shell")
+	fmt.Printf(format, "help", "prints this")
+	fmt.Printf(format, "connect", "initiate shell interaction for establishing TCP connection")
+	fmt.Printf(format, "balance", "look up a balance proven by full nodes")
+}
+
+func handleLightInput(input string, light *client.LightClient) (quit bool) {
+	switch input {
+	case "help":
+		printLightHelp()
+	case "connect":
+		address := getAddressFromUser()
+		if address == nil {
+			return false
+		}
+		err := light.Connect(address)
+		if err != nil {
+			fmt.Println("Error : ", err)
+		} else {
+			fmt.Println("Successfully connected to : " + address.String())
+		}
+	case "balance":
+		fmt.Println("Please enter account to look up balance:")
+		account := getString()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		balance, err := light.GetBalance(ctx, account)
+		cancel()
+		if err != nil {
+			fmt.Println("Error : ", err)
+			return false
+		}
+		fmt.Printf("Balance : %f (state root %x)\n", balance, light.Root())
+	case "quit":
+		errs := light.Close()
+		fmt.Println("Quitting ... ")
+		for err := range errs {
+			fmt.Println("Error closing connection : ", err)
+		}
+		return true
+	default:
+		fmt.Println("Illegal input: (Enter 'help' for list of commands)")
+	}
+	return false
+}
+
+// runLightClient runs the shell of a light client, which holds no ledger and so can't transfer.
+func runLightClient(cfg *config.Config) {
+	light, err := createAndConfigureLightClient(cfg)
+	if err != nil {
+		fmt.Println("Fatal error: ", err)
+		return
+	}
+	for _, addr := range light.GetAdvertisedAddrs() {
+		fmt.Println("Advertised as: " + addr.String())
+	}
+	fmt.Println("\nEnter 'help' for list of commands")
+	for {
+		fmt.Print(" >   ")
+		if handleLightInput(getString(), light) {
+			break
+		}
+	}
+}
+
 func main() {
 	registration.RegisterStructsWithGob()
-	client, err := createAndConfigureClient()
+	cfg, err := config.Parse(os.Args[1:])
+	if err != nil {
+		fmt.Println("Fatal error: ", err)
+		return
+	}
+	if cfg.Light {
+		runLightClient(cfg)
+		return
+	}
+	client, err := createAndConfigureClient(cfg)
 	if err != nil {
 		fmt.Println("Fatal error: ", err)
 		return
 	}
-	fmt.Println("Listening at IP: " + getExternalIP() + " : " + client.GetPort())
+	fmt.Println("Listening at port: " + client.GetPort())
+	for _, addr := range client.GetAdvertisedAddrs() {
+		fmt.Println("Advertised as: " + addr.String())
+	}
 	login(client)
 	fmt.Println("\nEnter 'help' for list of commands")
 	for {
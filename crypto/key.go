@@ -1,10 +1,13 @@
 package crypto
 
 import (
+	"errors"
 	"math/big"
 	"vicoin/internal/encoding"
 )
 
+var ErrInvalidKey = errors.New("invalid key")
+
 type PrivateKey struct {
 	N *big.Int
 	D *big.Int
@@ -18,8 +21,12 @@ func (privateKey *PrivateKey) FromString(str string) (*PrivateKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	privateKey.D = key.(PrivateKey).D
-	privateKey.N = key.(PrivateKey).N
+	decoded, ok := key.(PrivateKey)
+	if !ok || decoded.N == nil || decoded.D == nil || decoded.N.Sign() <= 0 || decoded.D.Sign() <= 0 {
+		return nil, ErrInvalidKey
+	}
+	privateKey.D = decoded.D
+	privateKey.N = decoded.N
 	return privateKey, nil
 }
 
@@ -36,7 +43,11 @@ func (publicKey *PublicKey) FromString(str string) (*PublicKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	publicKey.E = key.(PublicKey).E
-	publicKey.N = key.(PublicKey).N
+	decoded, ok := key.(PublicKey)
+	if !ok || decoded.N == nil || decoded.E == nil || decoded.N.Sign() <= 0 || decoded.E.Sign() <= 0 {
+		return nil, ErrInvalidKey
+	}
+	publicKey.E = decoded.E
+	publicKey.N = decoded.N
 	return publicKey, nil
 }
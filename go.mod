@@ -1,3 +1,3 @@
 module vicoin
 
-go 1.17
+go 1.20
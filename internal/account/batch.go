@@ -0,0 +1,124 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"vicoin/crypto"
+)
+
+// MaxOutputs bounds the outputs of a batch transaction.
+const MaxOutputs = 1000
+
+var (
+	ErrMissingRecipient = errors.New("output is missing a recipient")
+	ErrBatchAborted     = errors.New("output not applied as the batch failed")
+)
+
+// Output pays Amount to To, as one of the outputs of a batch transaction.
+type Output struct {
+	To     string
+	Amount float64
+}
+
+// OutputResult reports how an output of a batch transaction fared, Err being nil once applied.
+type OutputResult struct {
+	To     string
+	Amount float64
+	Err    error
+}
+
+// BatchError is returned for a batch transaction which wasn't applied, none of its outputs were.
+// Err is the cause, and Results tell which outputs caused it and which were merely aborted.
+type BatchError struct {
+	Err     error
+	Results []OutputResult
+}
+
+func (err *BatchError) Error() string {
+	failed := 0
+	for _, result := range err.Results {
+		if !errors.Is(result.Err, ErrBatchAborted) {
+			failed++
+		}
+	}
+	return fmt.Sprintf("batch of %d outputs not applied, %d failed: %v", len(err.Results), failed, err.Err)
+}
+
+func (err *BatchError) Unwrap() error {
+	return err.Err
+}
+
+// NewSignedBatch pays every output from from under a single signature, all or nothing.
+func NewSignedBatch(id string, from string, outputs []Output, fee float64, key *crypto.PrivateKey) (*SignedTransaction, error) {
+	return SignTransaction(Transaction{ID: id, From: from, Outputs: outputs, Fee: fee}, key)
+}
+
+// ValidatePayments checks the recipient and amount of every output, and that batches leave To and Amount unset,
+// reporting the outputs of a batch at fault in a BatchError.
+func ValidatePayments(transaction *SignedTransaction) error {
+	if len(transaction.Outputs) == 0 {
+		if transaction.To == "" {
+			return ErrMissingRecipient
+		}
+		if !validAmount(transaction.Amount) {
+			return ErrInvalidAmount
+		}
+		return nil
+	}
+	if len(transaction.Outputs) > MaxOutputs || transaction.To != "" || transaction.Amount != 0 {
+		return fmt.Errorf("%w: batch of %d outputs, want at most %d and no single recipient", ErrInvalidAmount, len(transaction.Outputs), MaxOutputs)
+	}
+	var cause error
+	results := make([]error, len(transaction.Outputs))
+	for i, output := range transaction.Outputs {
+		switch {
+		case output.To == "":
+			results[i] = ErrMissingRecipient
+		case !validAmount(output.Amount):
+			results[i] = ErrInvalidAmount
+		default:
+			continue
+		}
+		if cause == nil {
+			cause = results[i]
+		}
+	}
+	if cause == nil {
+		return nil
+	}
+	return reject(transaction, cause, func(i int) error { return results[i] })
+}
+
+// Internal
+
+// payments returns the outputs of the transaction, To and Amount being the only one unless it is a batch.
+func (signedTransaction *SignedTransaction) payments() []Output {
+	if len(signedTransaction.Outputs) == 0 {
+		return []Output{{To: signedTransaction.To, Amount: signedTransaction.Amount}}
+	}
+	return signedTransaction.Outputs
+}
+
+// reject returns cause, along with the result of each output of a batch, which failed returns, or nil if it was fine.
+func reject(transaction *SignedTransaction, cause error, failed func(i int) error) error {
+	if len(transaction.Outputs) == 0 {
+		return cause
+	}
+	results := make([]OutputResult, len(transaction.Outputs))
+	for i, output := range transaction.Outputs {
+		err := failed(i)
+		if err == nil {
+			err = ErrBatchAborted
+		}
+		results[i] = OutputResult{To: output.To, Amount: output.Amount, Err: err}
+	}
+	return &BatchError{Err: cause, Results: results}
+}
+
+func total(payments []Output) float64 {
+	sum := 0.0
+	for _, payment := range payments {
+		sum += payment.Amount
+	}
+	return sum
+}
@@ -0,0 +1,60 @@
+package account
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultFeeWindow is the number of transactions applied last that fee rates are estimated from.
+const DefaultFeeWindow = 100
+
+// FeeEstimator estimates the fee rate a transaction should pay from those of the transactions applied last,
+// rather than from those pending, which are mostly kept for lacking funds and say little of what gets applied.
+type FeeEstimator struct {
+	rates []float64 // Of the last transactions applied, the oldest overwritten first.
+	next  int
+	lock  sync.Mutex
+}
+
+// NewFeeEstimator returns an estimator over the last window transactions applied, which must be at least 1.
+func NewFeeEstimator(window int) (*FeeEstimator, error) {
+	if window < 1 {
+		return nil, fmt.Errorf("fee window %d, want at least 1", window)
+	}
+	return &FeeEstimator{
+		rates: make([]float64, 0, window),
+		next:  0,
+		lock:  sync.Mutex{},
+	}, nil
+}
+
+// Record notes the fee rate of a transaction applied. Issuances are exempt from fees, and so ignored.
+func (estimator *FeeEstimator) Record(transaction *SignedTransaction) {
+	if transaction.Issue {
+		return
+	}
+	estimator.lock.Lock()
+	defer estimator.lock.Unlock()
+	if len(estimator.rates) < cap(estimator.rates) {
+		estimator.rates = append(estimator.rates, transaction.FeeRate())
+		return
+	}
+	estimator.rates[estimator.next] = transaction.FeeRate()
+	estimator.next = (estimator.next + 1) % len(estimator.rates)
+}
+
+// Estimate returns the median fee rate of the transactions applied last, or minimum if higher or none were.
+func (estimator *FeeEstimator) Estimate(minimum float64) float64 {
+	estimator.lock.Lock()
+	rates := append([]float64{}, estimator.rates...)
+	estimator.lock.Unlock()
+	if len(rates) == 0 {
+		return minimum
+	}
+	sort.Float64s(rates)
+	if median := rates[len(rates)/2]; median > minimum {
+		return median
+	}
+	return minimum
+}
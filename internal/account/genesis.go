@@ -0,0 +1,125 @@
+package account
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+var ErrUnauthorizedIssuance = errors.New("issuance by an account which isn't a minter")
+
+// Allocation credits Account with Balance at genesis.
+type Allocation struct {
+	Account string
+	Balance float64
+}
+
+// GenesisParams are the rules every ledger of a network must apply alike.
+type GenesisParams struct {
+	MaxSupply    float64 // Bound on the total supply, including allocations, 0 for none.
+	MinFeeRate   float64 // Fee per byte below which transfers are refused.
+	FeeCollector string  // Account credited with fees, which are burned if empty.
+}
+
+// Genesis defines the initial state of a network. Its hash is part of the chain ID, so that only
+// nodes starting from the same allocations and applying the same rules connect to each other.
+// Besides the allocations, supply is only created by issuances signed by one of the Minters.
+type Genesis struct {
+	NetworkID   string
+	Allocations []Allocation
+	Minters     []string
+	Params      GenesisParams
+}
+
+// DefaultGenesis returns a genesis starting a network without supply nor any way to create it, as vicoin used to.
+// Each call returns a new copy, which callers may modify.
+func DefaultGenesis() *Genesis {
+	return &Genesis{
+		NetworkID:   "vicoin",
+		Allocations: []Allocation{},
+		Minters:     []string{},
+		Params:      GenesisParams{MaxSupply: 0, MinFeeRate: 0, FeeCollector: ""},
+	}
+}
+
+// LoadGenesis reads and validates a Genesis from a JSON file.
+func LoadGenesis(path string) (*Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	genesis := new(Genesis)
+	if err := json.Unmarshal(data, genesis); err != nil {
+		return nil, fmt.Errorf("invalid genesis file %q: %w", path, err)
+	}
+	if err := genesis.Validate(); err != nil {
+		return nil, err
+	}
+	return genesis, nil
+}
+
+// Validate checks that the parameters are sane, and the allocations positive, unique and within the maximum supply.
+func (genesis *Genesis) Validate() error {
+	if genesis.NetworkID == "" {
+		return errors.New("genesis is missing a network ID")
+	}
+	if !validAmount(genesis.Params.MaxSupply) && genesis.Params.MaxSupply != 0 {
+		return fmt.Errorf("invalid maximum supply %f", genesis.Params.MaxSupply)
+	}
+	if !validFee(genesis.Params.MinFeeRate) {
+		return fmt.Errorf("invalid minimum fee rate %f", genesis.Params.MinFeeRate)
+	}
+	allocated := make(map[string]bool)
+	supply := 0.0
+	for _, allocation := range genesis.Allocations {
+		if allocation.Account == "" || allocated[allocation.Account] {
+			return fmt.Errorf("missing or duplicate account allocated %q", allocation.Account)
+		}
+		if !validAmount(allocation.Balance) {
+			return fmt.Errorf("invalid allocation %f", allocation.Balance)
+		}
+		allocated[allocation.Account] = true
+		supply += allocation.Balance
+	}
+	if genesis.Params.MaxSupply > 0 && supply > genesis.Params.MaxSupply {
+		return fmt.Errorf("allocations of %f exceed the maximum supply %f", supply, genesis.Params.MaxSupply)
+	}
+	return nil
+}
+
+// Hash returns the hash of the JSON encoding of the genesis, fields and allocations in order.
+func (genesis *Genesis) Hash() [sha256.Size]byte {
+	data, err := json.Marshal(genesis)
+	if err != nil {
+		panic(err) // Only numbers, strings and slices of them.
+	}
+	return sha256.Sum256(data)
+}
+
+// ChainID identifies the network by its ID and the hash of its genesis.
+func (genesis *Genesis) ChainID() string {
+	hash := genesis.Hash()
+	return genesis.NetworkID + "-" + hex.EncodeToString(hash[:8])
+}
+
+// Internal
+func (genesis *Genesis) isMinter(account string) bool {
+	for _, minter := range genesis.Minters {
+		if minter == account {
+			return true
+		}
+	}
+	return false
+}
+
+func validAmount(amount float64) bool {
+	return !math.IsNaN(amount) && !math.IsInf(amount, 0) && amount > 0
+}
+
+func validFee(fee float64) bool {
+	return fee == 0 || validAmount(fee)
+}
@@ -3,4 +3,13 @@ package account
 type LedgerInterface interface {
 	SignedTransaction(transaction *SignedTransaction) error
 	GetBalance(account string) float64
+	Params() GenesisParams
+	Height() int
+	History(from int, limit int) []SignedTransaction
+	Summary() Summary
+	HashesIn(buckets []int) []TransactionHash
+	Lookup(hashes []TransactionHash) []SignedTransaction
+	StateRoot() StateHash
+	Digest() StateDigest
+	Prove(account string) StateProof
 }
@@ -2,58 +2,303 @@ package account
 
 import (
 	"errors"
+	"sort"
 	"sync"
-	"vicoin/crypto"
+	"time"
 )
 
+// Transactions may claim to have been admitted at most maxAdmissionSkew ahead of the clock of the ledger.
+const maxAdmissionSkew = 10 * time.Minute
+
+var (
+	ErrDuplicateTransaction = errors.New("transaction applied already")
+	ErrInsufficientFunds    = errors.New("insufficient funds")
+	ErrInvalidAmount        = errors.New("invalid amount")
+	ErrSupplyExceeded       = errors.New("issuance exceeds the maximum supply")
+	ErrFeeTooLow            = errors.New("fee rate below the minimum")
+	ErrNotYetValid          = errors.New("transaction not valid yet")
+	ErrExpired              = errors.New("transaction expired")
+	ErrFutureAdmission      = errors.New("transaction admitted in the future")
+)
+
+// Ledger keeps the balance of every account, along with the history of transactions applied, in order.
+// A state tree commits to the balance and nonce of every account, so that ledgers can be compared by root.
 type Ledger struct {
+	genesis  *Genesis
+	supply   float64
 	accounts map[string]float64
+	nonces   map[string]uint64
+	state    *stateTree
+	history  []SignedTransaction
+	index    map[TransactionHash]int // Position in history.
+	summary  Summary
 	lock     sync.Mutex
 }
 
+// NewLedger returns a ledger starting from DefaultGenesis.
 func NewLedger() *Ledger {
+	return NewGenesisLedger(DefaultGenesis())
+}
+
+// NewGenesisLedger returns a ledger starting from the allocations of a validated genesis, and applying its rules.
+func NewGenesisLedger(genesis *Genesis) *Ledger {
 	ledger := new(Ledger)
+	ledger.genesis = genesis
 	ledger.accounts = make(map[string]float64)
+	ledger.nonces = make(map[string]uint64)
+	ledger.state = newStateTree()
+	ledger.history = make([]SignedTransaction, 0)
+	ledger.index = make(map[TransactionHash]int)
+	for _, allocation := range genesis.Allocations {
+		ledger.accounts[allocation.Account] = allocation.Balance
+		ledger.supply += allocation.Balance
+		ledger.touch(allocation.Account)
+	}
 	return ledger
 }
 
+// SignedTransaction applies a transaction to the ledger, within its validity window at the height and the time
+// it was first admitted at, or if it wasn't yet, at the height of the ledger and now. The history records these,
+// so that ledgers replaying it check the same window whatever their own height and clock.
 func (ledger *Ledger) SignedTransaction(transaction *SignedTransaction) error {
 	ledger.lock.Lock()
 	defer ledger.lock.Unlock()
-	sendersPublicKey, err := new(crypto.PublicKey).FromString(transaction.From)
+	hash := transaction.Hash()
+	if _, ok := ledger.index[hash]; ok {
+		return ErrDuplicateTransaction
+	}
+	now := time.Now()
+	admitted := transaction.admission(len(ledger.history), now)
+	if admitted.Time > now.Add(maxAdmissionSkew).Unix() {
+		return ErrFutureAdmission
+	}
+	if err := transaction.ValidAt(admitted.Height, time.Unix(admitted.Time, 0)); err != nil {
+		return err
+	}
+	if !validFee(transaction.Fee) || (transaction.Issue && transaction.Fee != 0) {
+		return ErrInvalidAmount
+	}
+	if err := ValidatePayments(transaction); err != nil {
+		return err
+	}
+	var err error
+	if transaction.Issue {
+		err = ledger.issuable(transaction)
+	} else {
+		err = ledger.transferable(transaction)
+	}
 	if err != nil {
 		return err
 	}
-	validSignature, err := transaction.Validate(sendersPublicKey)
-	if err != nil || !validSignature {
-		return errors.New("unable to validate transaction")
+	// Verified last, being the most expensive check, so that retrying transactions lacking funds is cheap.
+	if err := transaction.Verify(); err != nil {
+		return err
 	}
-	if validSignature {
-		err := ledger.transfer(transaction.From, transaction.To, transaction.Amount)
-		if err != nil {
-			return err
-		}
+	if transaction.Issue {
+		ledger.issue(transaction)
+	} else {
+		ledger.transfer(transaction)
 	}
+	ledger.nonces[transaction.From]++
+	ledger.touch(transaction.From)
+	for _, payment := range transaction.payments() {
+		ledger.touch(payment.To)
+	}
+	if collector := ledger.genesis.Params.FeeCollector; collector != "" && transaction.Fee > 0 {
+		ledger.touch(collector)
+	}
+	ledger.index[hash] = len(ledger.history)
+	entry := *transaction
+	entry.Admitted = &admitted
+	ledger.history = append(ledger.history, entry)
+	ledger.summary.add(hash)
 	return nil
 }
 
+// Height returns the number of transactions applied.
+func (ledger *Ledger) Height() int {
+	ledger.lock.Lock()
+	defer ledger.lock.Unlock()
+	return len(ledger.history)
+}
+
+// History returns up to limit applied transactions, starting at the from'th.
+func (ledger *Ledger) History(from int, limit int) []SignedTransaction {
+	ledger.lock.Lock()
+	defer ledger.lock.Unlock()
+	if from < 0 || from >= len(ledger.history) {
+		return []SignedTransaction{}
+	}
+	to := from + limit
+	if to > len(ledger.history) {
+		to = len(ledger.history)
+	}
+	return append([]SignedTransaction{}, ledger.history[from:to]...)
+}
+
+// Summary returns a digest of the transactions applied, regardless of their order.
+func (ledger *Ledger) Summary() Summary {
+	ledger.lock.Lock()
+	defer ledger.lock.Unlock()
+	return ledger.summary
+}
+
+// HashesIn returns the hashes of the transactions applied which fall into the given summary buckets.
+func (ledger *Ledger) HashesIn(buckets []int) []TransactionHash {
+	ledger.lock.Lock()
+	defer ledger.lock.Unlock()
+	wanted := make(map[int]bool)
+	for _, bucket := range buckets {
+		wanted[bucket] = true
+	}
+	hashes := make([]TransactionHash, 0)
+	for hash := range ledger.index {
+		if wanted[bucketOf(hash)] {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// Lookup returns the applied transactions with the given hashes, in the order they were applied.
+func (ledger *Ledger) Lookup(hashes []TransactionHash) []SignedTransaction {
+	ledger.lock.Lock()
+	defer ledger.lock.Unlock()
+	positions := make([]int, 0, len(hashes))
+	for _, hash := range hashes {
+		if position, ok := ledger.index[hash]; ok {
+			positions = append(positions, position)
+		}
+	}
+	sort.Ints(positions)
+	transactions := make([]SignedTransaction, 0, len(positions))
+	for _, position := range positions {
+		transactions = append(transactions, ledger.history[position])
+	}
+	return transactions
+}
+
 func (ledger *Ledger) GetBalance(account string) float64 {
 	ledger.lock.Lock()
 	defer ledger.lock.Unlock()
 	return ledger.accounts[account]
 }
 
-func (ledger *Ledger) transfer(from string, to string, amount float64) error {
-	if ledger.accounts[from] < amount {
-		return errors.New("insufficient funds")
+// StateRoot returns the root of the state tree, equal for ledgers agreeing on the state of every account.
+func (ledger *Ledger) StateRoot() StateHash {
+	ledger.lock.Lock()
+	defer ledger.lock.Unlock()
+	return ledger.state.root()
+}
+
+// Digest returns the state root along with the height it was reached at.
+func (ledger *Ledger) Digest() StateDigest {
+	ledger.lock.Lock()
+	defer ledger.lock.Unlock()
+	return StateDigest{Height: len(ledger.history), Root: ledger.state.root()}
+}
+
+// Prove returns a proof of the state of account under the current state root,
+// or of its absence should the ledger not know it.
+func (ledger *Ledger) Prove(account string) StateProof {
+	ledger.lock.Lock()
+	defer ledger.lock.Unlock()
+	proof := ledger.state.prove(account)
+	if balance, ok := ledger.accounts[account]; ok {
+		proof.Exists = true
+		proof.State = AccountState{Balance: balance, Nonce: ledger.nonces[account]}
+	}
+	return proof
+}
+
+// Params returns the rules the ledger applies.
+func (ledger *Ledger) Params() GenesisParams {
+	return ledger.genesis.Params
+}
+
+// Supply returns the total of all balances, allocated at genesis or issued since.
+func (ledger *Ledger) Supply() float64 {
+	ledger.lock.Lock()
+	defer ledger.lock.Unlock()
+	return ledger.supply
+}
+
+// touch updates the state tree with the state of account, which is known from then on. Requires ledger.lock to be held.
+func (ledger *Ledger) touch(account string) {
+	if _, ok := ledger.accounts[account]; !ok {
+		ledger.accounts[account] = 0
+	}
+	ledger.state.update(account, AccountState{Balance: ledger.accounts[account], Nonce: ledger.nonces[account]})
+}
+
+// transferable checks that the sender can pay every output and the fee, at a rate no lower than the minimum.
+func (ledger *Ledger) transferable(transaction *SignedTransaction) error {
+	if transaction.FeeRate() < ledger.genesis.Params.MinFeeRate {
+		return ErrFeeTooLow
+	}
+	payments := transaction.payments()
+	if available := ledger.accounts[transaction.From] - transaction.Fee; available < total(payments) {
+		return reject(transaction, ErrInsufficientFunds, exceeding(payments, available, ErrInsufficientFunds))
+	}
+	return nil
+}
+
+// transfer pays every output from the sender, and the fee to the collector, if any. Requires it to be transferable.
+func (ledger *Ledger) transfer(transaction *SignedTransaction) {
+	payments := transaction.payments()
+	ledger.accounts[transaction.From] -= total(payments) + transaction.Fee
+	for _, payment := range payments {
+		ledger.accounts[payment.To] += payment.Amount
+	}
+	if collector := ledger.genesis.Params.FeeCollector; collector != "" {
+		ledger.accounts[collector] += transaction.Fee
+	} else {
+		ledger.supply -= transaction.Fee
+	}
+}
+
+// issuable checks that the sender is a minter, and that every output fits within the maximum supply.
+func (ledger *Ledger) issuable(transaction *SignedTransaction) error {
+	if !ledger.genesis.isMinter(transaction.From) {
+		return ErrUnauthorizedIssuance
+	}
+	payments := transaction.payments()
+	if maximum := ledger.genesis.Params.MaxSupply; maximum > 0 && ledger.supply+total(payments) > maximum {
+		return reject(transaction, ErrSupplyExceeded, exceeding(payments, maximum-ledger.supply, ErrSupplyExceeded))
 	}
-	ledger.accounts[from] -= amount
-	ledger.accounts[to] += amount
 	return nil
 }
 
+// issue creates every output. Requires it to be issuable.
+func (ledger *Ledger) issue(transaction *SignedTransaction) {
+	payments := transaction.payments()
+	for _, payment := range payments {
+		ledger.accounts[payment.To] += payment.Amount
+	}
+	ledger.supply += total(payments)
+}
+
+// exceeding fails with err the payments from the first one exceeding what is available once paid in order.
+func exceeding(payments []Output, available float64, err error) func(i int) error {
+	paid := make([]float64, len(payments)) // Once the i'th payment is.
+	sum := 0.0
+	for i, payment := range payments {
+		sum += payment.Amount
+		paid[i] = sum
+	}
+	return func(i int) error {
+		if paid[i] > available {
+			return err
+		}
+		return nil
+	}
+}
+
+// SetBalance bypasses the rules of the genesis, and is only meant for setting up tests.
 func (ledger *Ledger) SetBalance(account string, amount float64) {
 	ledger.lock.Lock()
 	defer ledger.lock.Unlock()
+	ledger.supply += amount - ledger.accounts[account]
 	ledger.accounts[account] = amount
+	ledger.touch(account)
 }
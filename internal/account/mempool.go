@@ -0,0 +1,211 @@
+package account
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMempoolCapacity bounds the transactions pending, beyond which those paying the lowest fee rates are evicted.
+const DefaultMempoolCapacity = 5000
+
+var ErrMempoolFull = errors.New("mempool full of transactions paying higher fee rates")
+
+// Mempool holds the transactions which can't be applied yet, such as those spending funds still to arrive
+// or not valid yet, handing them out by decreasing fee rate, overall or by sender.
+type Mempool struct {
+	capacity int
+	pending  map[TransactionHash]*pooled
+	senders  map[string]map[TransactionHash]*pooled
+	waiting  map[TransactionHash]*pooled // Only valid from a height or a time.
+	cheapest feeOrder
+	lock     sync.Mutex
+}
+
+// NewMempool returns a mempool holding up to capacity transactions, which must be at least 1.
+func NewMempool(capacity int) (*Mempool, error) {
+	if capacity < 1 {
+		return nil, fmt.Errorf("mempool capacity %d, want at least 1", capacity)
+	}
+	return &Mempool{
+		capacity: capacity,
+		pending:  make(map[TransactionHash]*pooled),
+		senders:  make(map[string]map[TransactionHash]*pooled),
+		waiting:  make(map[TransactionHash]*pooled),
+		cheapest: make(feeOrder, 0),
+		lock:     sync.Mutex{},
+	}, nil
+}
+
+// Add adds a transaction, evicting the one paying the lowest fee rate if full,
+// and refusing the transaction with ErrMempoolFull if it pays the lowest.
+func (mempool *Mempool) Add(transaction SignedTransaction) error {
+	mempool.lock.Lock()
+	defer mempool.lock.Unlock()
+	hash := transaction.Hash()
+	if _, ok := mempool.pending[hash]; ok {
+		return nil
+	}
+	entry := &pooled{transaction: transaction, hash: hash, rate: transaction.FeeRate(), index: 0}
+	if len(mempool.pending) >= mempool.capacity {
+		if !higher(entry, mempool.cheapest[0]) {
+			return ErrMempoolFull
+		}
+		mempool.remove(mempool.cheapest[0])
+	}
+	mempool.pending[hash] = entry
+	if mempool.senders[transaction.From] == nil {
+		mempool.senders[transaction.From] = make(map[TransactionHash]*pooled)
+	}
+	mempool.senders[transaction.From][hash] = entry
+	if transaction.NotBefore > 0 {
+		mempool.waiting[hash] = entry
+	}
+	heap.Push(&mempool.cheapest, entry)
+	return nil
+}
+
+func (mempool *Mempool) Remove(hash TransactionHash) {
+	mempool.lock.Lock()
+	defer mempool.lock.Unlock()
+	if entry, ok := mempool.pending[hash]; ok {
+		mempool.remove(entry)
+	}
+}
+
+// Pending returns the transactions pending, by decreasing fee rate.
+func (mempool *Mempool) Pending() []SignedTransaction {
+	mempool.lock.Lock()
+	defer mempool.lock.Unlock()
+	return sorted(mempool.pending)
+}
+
+// PendingFrom returns the transactions pending sent by sender, by decreasing fee rate.
+func (mempool *Mempool) PendingFrom(sender string) []SignedTransaction {
+	mempool.lock.Lock()
+	defer mempool.lock.Unlock()
+	return sorted(mempool.senders[sender])
+}
+
+// Senders returns the senders of the transactions pending.
+func (mempool *Mempool) Senders() []string {
+	mempool.lock.Lock()
+	defer mempool.lock.Unlock()
+	senders := make([]string, 0, len(mempool.senders))
+	for sender := range mempool.senders {
+		senders = append(senders, sender)
+	}
+	sort.Strings(senders)
+	return senders
+}
+
+// Ripe returns the senders of the transactions pending only valid from a height or a time, reached at height and now,
+// or at their admission for those of the history of peers.
+func (mempool *Mempool) Ripe(height int, now time.Time) []string {
+	mempool.lock.Lock()
+	defer mempool.lock.Unlock()
+	senders := make([]string, 0)
+	for _, entry := range mempool.waiting {
+		admitted := entry.transaction.admission(height, now)
+		if entry.transaction.ValidAt(admitted.Height, time.Unix(admitted.Time, 0)) == nil {
+			senders = append(senders, entry.transaction.From)
+		}
+	}
+	return senders
+}
+
+// Expire drops the transactions expired at height, or at now, or at their admission for those of the history of peers.
+func (mempool *Mempool) Expire(height int, now time.Time) {
+	mempool.lock.Lock()
+	defer mempool.lock.Unlock()
+	for _, entry := range mempool.pending {
+		admitted := entry.transaction.admission(height, now)
+		if errors.Is(entry.transaction.ValidAt(admitted.Height, time.Unix(admitted.Time, 0)), ErrExpired) {
+			mempool.remove(entry)
+		}
+	}
+}
+
+func (mempool *Mempool) Len() int {
+	mempool.lock.Lock()
+	defer mempool.lock.Unlock()
+	return len(mempool.pending)
+}
+
+// Internal
+
+// pooled is a transaction pending, along with its fee rate and its position in the eviction order.
+type pooled struct {
+	transaction SignedTransaction
+	hash        TransactionHash
+	rate        float64
+	index       int
+}
+
+// remove drops entry from the pending transactions and every index of them. Requires mempool.lock to be held.
+func (mempool *Mempool) remove(entry *pooled) {
+	delete(mempool.pending, entry.hash)
+	delete(mempool.waiting, entry.hash)
+	if sent := mempool.senders[entry.transaction.From]; sent != nil {
+		delete(sent, entry.hash)
+		if len(sent) == 0 {
+			delete(mempool.senders, entry.transaction.From)
+		}
+	}
+	heap.Remove(&mempool.cheapest, entry.index)
+}
+
+// higher reports whether first is handed out before second, paying a higher fee rate, or the same with a lower ID.
+func higher(first *pooled, second *pooled) bool {
+	if first.rate != second.rate {
+		return first.rate > second.rate
+	}
+	return first.transaction.ID < second.transaction.ID
+}
+
+func sorted(entries map[TransactionHash]*pooled) []SignedTransaction {
+	ordered := make([]*pooled, 0, len(entries))
+	for _, entry := range entries {
+		ordered = append(ordered, entry)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return higher(ordered[i], ordered[j])
+	})
+	transactions := make([]SignedTransaction, 0, len(ordered))
+	for _, entry := range ordered {
+		transactions = append(transactions, entry.transaction)
+	}
+	return transactions
+}
+
+// feeOrder is a heap of the transactions pending, the first evicted first.
+type feeOrder []*pooled
+
+func (order feeOrder) Len() int {
+	return len(order)
+}
+
+func (order feeOrder) Less(i, j int) bool {
+	return higher(order[j], order[i])
+}
+
+func (order feeOrder) Swap(i, j int) {
+	order[i], order[j] = order[j], order[i]
+	order[i].index = i
+	order[j].index = j
+}
+
+func (order *feeOrder) Push(entry interface{}) {
+	entry.(*pooled).index = len(*order)
+	*order = append(*order, entry.(*pooled))
+}
+
+func (order *feeOrder) Pop() interface{} {
+	old := *order
+	entry := old[len(old)-1]
+	*order = old[:len(old)-1]
+	return entry
+}
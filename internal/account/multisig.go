@@ -0,0 +1,161 @@
+package account
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"vicoin/crypto"
+	"vicoin/internal/encoding"
+)
+
+// MaxMultisigKeys bounds the keys of a multisig policy.
+const MaxMultisigKeys = 16
+
+var (
+	ErrThresholdNotMet = errors.New("fewer valid signatures than the threshold")
+	ErrNotASigner      = errors.New("key isn't one of the policy")
+)
+
+// MultisigPolicy controls an account by Threshold of its Keys, the strings of crypto.PublicKeys.
+type MultisigPolicy struct {
+	Threshold int
+	Keys      []string
+}
+
+// Validate checks that the threshold can be met by distinct keys.
+func (policy *MultisigPolicy) Validate() error {
+	if len(policy.Keys) == 0 || len(policy.Keys) > MaxMultisigKeys {
+		return fmt.Errorf("policy of %d keys, want 1 to %d", len(policy.Keys), MaxMultisigKeys)
+	}
+	if policy.Threshold < 1 || policy.Threshold > len(policy.Keys) {
+		return fmt.Errorf("threshold %d of %d keys", policy.Threshold, len(policy.Keys))
+	}
+	seen := make(map[string]bool)
+	for _, key := range policy.Keys {
+		if seen[key] {
+			return errors.New("policy has duplicate keys")
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Account returns the account the policy controls, derived from the threshold and the keys in order.
+func (policy *MultisigPolicy) Account() string {
+	hash := sha256.Sum256([]byte(strconv.Itoa(policy.Threshold) + "/" + strings.Join(policy.Keys, "/")))
+	return "multisig:" + hex.EncodeToString(hash[:])
+}
+
+// NewMultisigTransaction returns transaction from the account of policy, partially signed by none of its keys yet.
+// Each key holder adds a signature with AddSignature, in any order and offline, combining them with Combine.
+func NewMultisigTransaction(transaction Transaction, policy MultisigPolicy) (*SignedTransaction, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	transaction.From = policy.Account()
+	return &SignedTransaction{
+		ID:         transaction.ID,
+		From:       transaction.From,
+		To:         transaction.To,
+		Amount:     transaction.Amount,
+		Fee:        transaction.Fee,
+		Issue:      transaction.Issue,
+		NotBefore:  transaction.NotBefore,
+		ExpiresAt:  transaction.ExpiresAt,
+		Outputs:    transaction.Outputs,
+		Signature:  "",
+		Multisig:   &policy,
+		Signatures: make([]string, len(policy.Keys)),
+	}, nil
+}
+
+// AddSignature signs the transaction with the key of the policy public belongs to.
+func (signedTransaction *SignedTransaction) AddSignature(public *crypto.PublicKey, private *crypto.PrivateKey) error {
+	if signedTransaction.Multisig == nil {
+		return errors.New("transaction isn't from a multisig account")
+	}
+	key, err := public.ToString()
+	if err != nil {
+		return err
+	}
+	for i, signer := range signedTransaction.Multisig.Keys {
+		if signer == key {
+			signature, err := crypto.Sign(signedTransaction.unsigned(), private)
+			if err != nil {
+				return err
+			}
+			signedTransaction.Signatures[i] = string(signature)
+			return nil
+		}
+	}
+	return ErrNotASigner
+}
+
+// Combine adds the signatures of other, the same transaction signed by other keys.
+func (signedTransaction *SignedTransaction) Combine(other *SignedTransaction) error {
+	if signedTransaction.Multisig == nil || signedTransaction.Hash() != other.Hash() || len(other.Signatures) != len(signedTransaction.Signatures) {
+		return errors.New("transactions differ")
+	}
+	for i, signature := range other.Signatures {
+		if signature != "" {
+			signedTransaction.Signatures[i] = signature
+		}
+	}
+	return nil
+}
+
+// Signers returns the number of valid signatures.
+func (signedTransaction *SignedTransaction) Signers() int {
+	if signedTransaction.Multisig == nil || len(signedTransaction.Signatures) != len(signedTransaction.Multisig.Keys) {
+		return 0
+	}
+	signers := 0
+	for i, signature := range signedTransaction.Signatures {
+		key, err := new(crypto.PublicKey).FromString(signedTransaction.Multisig.Keys[i])
+		if err != nil || signature == "" {
+			continue
+		}
+		if valid, err := crypto.Validate(signedTransaction.unsigned(), []byte(signature), key); err == nil && valid {
+			signers++
+		}
+	}
+	return signers
+}
+
+// ToString encodes the partially signed transaction, for passing it to the next key holder.
+func (signedTransaction *SignedTransaction) ToString() (string, error) {
+	return encoding.ToB64(*signedTransaction)
+}
+
+func (signedTransaction *SignedTransaction) FromString(str string) (*SignedTransaction, error) {
+	decoded, err := encoding.FromB64(str)
+	if err != nil {
+		return nil, err
+	}
+	transaction, ok := decoded.(SignedTransaction)
+	if !ok {
+		return nil, errors.New("not a transaction")
+	}
+	*signedTransaction = transaction
+	return signedTransaction, nil
+}
+
+// Internal
+
+// verifyMultisig checks that the policy controls the sender, and that enough of its keys signed.
+func (signedTransaction *SignedTransaction) verifyMultisig() error {
+	policy := signedTransaction.Multisig
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	if policy.Account() != signedTransaction.From {
+		return errors.New("policy doesn't control the sender")
+	}
+	if signedTransaction.Signers() < policy.Threshold {
+		return ErrThresholdNotMet
+	}
+	return nil
+}
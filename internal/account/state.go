@@ -0,0 +1,175 @@
+package account
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// stateDepth is the depth of the state tree, one level per bit of the hash of an account.
+const stateDepth = sha256.Size * 8
+
+// Balances are committed to in units of 1/stateUnit, so that ledgers summing the same amounts
+// in a different order, and so rounding differently, agree on the state root.
+const stateUnit = 1e8
+
+var ErrInvalidProof = errors.New("invalid state proof")
+
+// StateHash is the hash of a subtree of the state tree, the zero hash standing for an empty subtree.
+type StateHash [sha256.Size]byte
+
+// AccountState is what the state tree commits to for an account. Nonce counts the transactions it sent.
+type AccountState struct {
+	Balance float64
+	Nonce   uint64
+}
+
+// StateDigest is the state root of a ledger along with the number of transactions applied to reach it.
+type StateDigest struct {
+	Height int
+	Root   StateHash
+}
+
+// StateProof proves the state of Account, or its absence, under a state root.
+type StateProof struct {
+	Account  string
+	Exists   bool
+	State    AccountState
+	Present  [stateDepth / 8]byte // Bit i is set when the sibling at depth i+1 isn't empty.
+	Siblings []StateHash          // The siblings which aren't empty, from the root down.
+}
+
+// Verify checks that the proof leads to root, returning ErrInvalidProof otherwise.
+func (proof StateProof) Verify(root StateHash) error {
+	key := stateKey(proof.Account)
+	hash := StateHash{}
+	if proof.Exists {
+		hash = leafHash(key, proof.State)
+	} else if proof.State != (AccountState{}) {
+		return ErrInvalidProof
+	}
+	next := len(proof.Siblings) - 1
+	for depth := stateDepth; depth > 0; depth-- {
+		sibling := StateHash{}
+		if bit(StateHash(proof.Present), depth-1) == 1 {
+			if next < 0 {
+				return ErrInvalidProof
+			}
+			sibling = proof.Siblings[next]
+			next--
+		}
+		if bit(key, depth-1) == 0 {
+			hash = nodeHash(hash, sibling)
+		} else {
+			hash = nodeHash(sibling, hash)
+		}
+	}
+	if next != -1 || hash != root {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// stateTree is a sparse Merkle tree over the state of accounts, keyed by the hash of the account.
+// Only subtrees which aren't empty are stored, by depth and the prefix of the keys below them.
+type stateTree struct {
+	nodes map[stateNode]StateHash
+}
+
+type stateNode struct {
+	depth  int
+	prefix StateHash
+}
+
+func newStateTree() *stateTree {
+	return &stateTree{
+		nodes: make(map[stateNode]StateHash),
+	}
+}
+
+func (tree *stateTree) root() StateHash {
+	return tree.nodes[stateNode{depth: 0, prefix: StateHash{}}]
+}
+
+// update sets the state of account, hashing the path from its leaf up to the root again.
+func (tree *stateTree) update(account string, state AccountState) {
+	key := stateKey(account)
+	hash := leafHash(key, state)
+	tree.set(stateNode{depth: stateDepth, prefix: key}, hash)
+	for depth := stateDepth - 1; depth >= 0; depth-- {
+		sibling := tree.nodes[siblingOf(key, depth+1)]
+		if bit(key, depth) == 0 {
+			hash = nodeHash(hash, sibling)
+		} else {
+			hash = nodeHash(sibling, hash)
+		}
+		tree.set(stateNode{depth: depth, prefix: prefix(key, depth)}, hash)
+	}
+}
+
+// prove returns the siblings of the path to the leaf of account.
+func (tree *stateTree) prove(account string) StateProof {
+	key := stateKey(account)
+	proof := StateProof{Account: account, Siblings: make([]StateHash, 0)}
+	for depth := 1; depth <= stateDepth; depth++ {
+		sibling, ok := tree.nodes[siblingOf(key, depth)]
+		if ok {
+			proof.Present[(depth-1)/8] |= 1 << (7 - (depth-1)%8)
+			proof.Siblings = append(proof.Siblings, sibling)
+		}
+	}
+	return proof
+}
+
+func (tree *stateTree) set(node stateNode, hash StateHash) {
+	if hash == (StateHash{}) {
+		delete(tree.nodes, node)
+		return
+	}
+	tree.nodes[node] = hash
+}
+
+func stateKey(account string) StateHash {
+	return sha256.Sum256([]byte(account))
+}
+
+func leafHash(key StateHash, state AccountState) StateHash {
+	data := make([]byte, 0, 1+len(key)+16)
+	data = append(data, 0)
+	data = append(data, key[:]...)
+	data = binary.BigEndian.AppendUint64(data, uint64(int64(math.Round(state.Balance*stateUnit))))
+	data = binary.BigEndian.AppendUint64(data, state.Nonce)
+	return sha256.Sum256(data)
+}
+
+// nodeHash keeps a subtree with two empty children empty, so that empty subtrees need no storing.
+func nodeHash(left StateHash, right StateHash) StateHash {
+	if left == (StateHash{}) && right == (StateHash{}) {
+		return StateHash{}
+	}
+	data := make([]byte, 0, 1+2*len(left))
+	data = append(data, 1)
+	data = append(data, left[:]...)
+	data = append(data, right[:]...)
+	return sha256.Sum256(data)
+}
+
+// siblingOf returns the sibling of the node at depth on the path to key.
+func siblingOf(key StateHash, depth int) stateNode {
+	sibling := prefix(key, depth)
+	sibling[(depth-1)/8] ^= 1 << (7 - (depth-1)%8)
+	return stateNode{depth: depth, prefix: sibling}
+}
+
+// prefix keeps the first depth bits of key.
+func prefix(key StateHash, depth int) StateHash {
+	for i := depth; i < stateDepth; i++ {
+		key[i/8] &^= 1 << (7 - i%8)
+	}
+	return key
+}
+
+func bit(key StateHash, i int) byte {
+	return key[i/8] >> (7 - i%8) & 1
+}
@@ -0,0 +1,51 @@
+package account
+
+import (
+	"crypto/sha256"
+	"vicoin/internal/encoding"
+)
+
+// SummaryBuckets is the number of ranges of transaction hashes a Summary digests separately.
+const SummaryBuckets = 256
+
+// TransactionHash identifies a signed transaction.
+type TransactionHash [sha256.Size]byte
+
+// Summary digests a set of transactions per range of their hashes, as the XOR of the hashes
+// in the range. Two ledgers differ in a range exactly when its digests differ, barring collisions,
+// whatever order they applied the transactions in.
+type Summary [SummaryBuckets]TransactionHash
+
+// Hash identifies the transaction by what its sender signed, as two ledgers must never apply both of two transactions
+// sharing it. Signatures are left out: copies of a multisig transaction may be signed by different keys, and a signature
+// plus the modulus of its key verifies all the same, which would let copies of a transaction pass for new ones.
+func (transaction *SignedTransaction) Hash() TransactionHash {
+	serialized, err := encoding.Serialize(transaction.unsigned())
+	if err != nil {
+		panic(err) // Only for Transaction not registered with gob, which signing and validating need as well.
+	}
+	return sha256.Sum256(serialized)
+}
+
+// Differences returns the buckets in which the summaries differ.
+func (summary Summary) Differences(other Summary) []int {
+	buckets := make([]int, 0)
+	for bucket := range summary {
+		if summary[bucket] != other[bucket] {
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets
+}
+
+// Internal
+func (summary *Summary) add(hash TransactionHash) {
+	digest := &summary[bucketOf(hash)]
+	for i := range digest {
+		digest[i] ^= hash[i]
+	}
+}
+
+func bucketOf(hash TransactionHash) int {
+	return int(hash[0])
+}
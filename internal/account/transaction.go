@@ -1,53 +1,172 @@
 package account
 
 import (
+	"errors"
+	"time"
 	"vicoin/crypto"
 )
 
-type Transaction struct {
-	ID     string
-	From   string
-	To     string
-	Amount float64
-}
+// HeightThreshold separates the bounds of validity windows expressed as heights of the ledger,
+// below it, from those expressed as Unix timestamps in seconds.
+const HeightThreshold = 500000000
 
-type SignedTransaction struct {
+// Transaction moves Amount from From to To, or each of its Outputs in a batch, unless it is an issuance, which creates Amount for To
+// and is only valid when From is one of the minters of the genesis. From pays Fee on top of Amount,
+// which issuances are exempt from. It is only valid from NotBefore and until ExpiresAt, if set,
+// each either a height of the ledger, the number of transactions applied before, or a timestamp.
+type Transaction struct {
 	ID        string
 	From      string
 	To        string
 	Amount    float64
-	Signature string
+	Fee       float64
+	Issue     bool
+	NotBefore int64
+	ExpiresAt int64
+	Outputs   []Output
+}
+
+type SignedTransaction struct {
+	ID         string
+	From       string
+	To         string
+	Amount     float64
+	Fee        float64
+	Issue      bool
+	NotBefore  int64
+	ExpiresAt  int64
+	Outputs    []Output
+	Signature  string
+	Multisig   *MultisigPolicy // Controlling From, which then signed with Signatures instead of Signature.
+	Signatures []string        // By the key of Multisig at the same index, empty where missing.
+	Admitted   *Admission      // Recorded by the ledger first applying it, nil until then.
+}
+
+// Admission is the height of the ledger and the Unix time a transaction was first applied at. Ledgers applying
+// it since check its validity window against those, as their own height and clock differ from the first's.
+type Admission struct {
+	Height int
+	Time   int64
 }
 
 func NewSignedTransaction(id string, from string, to string, amount float64, key *crypto.PrivateKey) (*SignedTransaction, error) {
-	unsignedTransaction := Transaction{
-		ID:     id,
-		From:   from,
-		To:     to,
-		Amount: amount,
-	}
+	return NewSignedTransactionWithFee(id, from, to, amount, 0, key)
+}
+
+func NewSignedTransactionWithFee(id string, from string, to string, amount float64, fee float64, key *crypto.PrivateKey) (*SignedTransaction, error) {
+	return SignTransaction(Transaction{ID: id, From: from, To: to, Amount: amount, Fee: fee, Issue: false}, key)
+}
+
+// NewSignedIssuance creates amount for to, signed by the minter from.
+func NewSignedIssuance(id string, from string, to string, amount float64, key *crypto.PrivateKey) (*SignedTransaction, error) {
+	return SignTransaction(Transaction{ID: id, From: from, To: to, Amount: amount, Fee: 0, Issue: true}, key)
+}
+
+// SignTransaction signs any transaction, such as one only valid within a window.
+func SignTransaction(unsignedTransaction Transaction, key *crypto.PrivateKey) (*SignedTransaction, error) {
 	signature, err := crypto.Sign(unsignedTransaction, key)
 	if err != nil {
 		return nil, err
 	}
 	return &SignedTransaction{
-		ID:        id,
-		From:      from,
-		To:        to,
-		Amount:    amount,
-		Signature: string(signature),
+		ID:         unsignedTransaction.ID,
+		From:       unsignedTransaction.From,
+		To:         unsignedTransaction.To,
+		Amount:     unsignedTransaction.Amount,
+		Fee:        unsignedTransaction.Fee,
+		Issue:      unsignedTransaction.Issue,
+		NotBefore:  unsignedTransaction.NotBefore,
+		ExpiresAt:  unsignedTransaction.ExpiresAt,
+		Outputs:    unsignedTransaction.Outputs,
+		Signature:  string(signature),
+		Multisig:   nil,
+		Signatures: nil,
 	}, nil
 }
 func (signedTransaction *SignedTransaction) Validate(key *crypto.PublicKey) (isValid bool, err error) {
-	unsignedTransaction := Transaction{
-		ID:     signedTransaction.ID,
-		From:   signedTransaction.From,
-		To:     signedTransaction.To,
-		Amount: signedTransaction.Amount,
-	}
-	isValid, err = crypto.Validate(unsignedTransaction, []byte(signedTransaction.Signature), key)
+	isValid, err = crypto.Validate(signedTransaction.unsigned(), []byte(signedTransaction.Signature), key)
 	if err != nil {
 		return false, err
 	}
 	return isValid, err
 }
+
+// Verify checks the signature of the sender, or that enough of the keys of its multisig policy signed.
+func (signedTransaction *SignedTransaction) Verify() error {
+	if signedTransaction.Multisig != nil {
+		return signedTransaction.verifyMultisig()
+	}
+	sendersPublicKey, err := new(crypto.PublicKey).FromString(signedTransaction.From)
+	if err != nil {
+		return err
+	}
+	validSignature, err := signedTransaction.Validate(sendersPublicKey)
+	if err != nil || !validSignature {
+		return errors.New("unable to validate transaction")
+	}
+	return nil
+}
+
+// ValidAt returns ErrNotYetValid or ErrExpired if the transaction can't be applied at height, or at now.
+func (signedTransaction *SignedTransaction) ValidAt(height int, now time.Time) error {
+	if signedTransaction.NotBefore > 0 && !reached(signedTransaction.NotBefore, height, now) {
+		return ErrNotYetValid
+	}
+	if signedTransaction.ExpiresAt > 0 && reached(signedTransaction.ExpiresAt, height, now) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// admission returns when the transaction was first admitted, or if it wasn't yet, height and now.
+func (signedTransaction *SignedTransaction) admission(height int, now time.Time) Admission {
+	if signedTransaction.Admitted != nil {
+		return *signedTransaction.Admitted
+	}
+	return Admission{Height: height, Time: now.Unix()}
+}
+
+// Size approximates the encoded size of the transaction in bytes, which its fee rate is relative to.
+func (signedTransaction *SignedTransaction) Size() int {
+	size := len(signedTransaction.ID) + len(signedTransaction.From) + len(signedTransaction.To) + len(signedTransaction.Signature) + 33
+	for _, output := range signedTransaction.Outputs {
+		size += len(output.To) + 8
+	}
+	if signedTransaction.Multisig != nil {
+		for i, key := range signedTransaction.Multisig.Keys {
+			size += len(key)
+			if i < len(signedTransaction.Signatures) {
+				size += len(signedTransaction.Signatures[i])
+			}
+		}
+	}
+	return size
+}
+
+// FeeRate returns the fee paid per byte.
+func (signedTransaction *SignedTransaction) FeeRate() float64 {
+	return signedTransaction.Fee / float64(signedTransaction.Size())
+}
+
+// unsigned returns what the sender signed.
+func (signedTransaction *SignedTransaction) unsigned() Transaction {
+	return Transaction{
+		ID:        signedTransaction.ID,
+		From:      signedTransaction.From,
+		To:        signedTransaction.To,
+		Amount:    signedTransaction.Amount,
+		Fee:       signedTransaction.Fee,
+		Issue:     signedTransaction.Issue,
+		NotBefore: signedTransaction.NotBefore,
+		ExpiresAt: signedTransaction.ExpiresAt,
+		Outputs:   signedTransaction.Outputs,
+	}
+}
+
+// reached reports whether bound, a height or a timestamp, is reached at height and now.
+func reached(bound int64, height int, now time.Time) bool {
+	if bound < HeightThreshold {
+		return int64(height) >= bound
+	}
+	return now.Unix() >= bound
+}
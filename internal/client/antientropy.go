@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+	"vicoin/internal/account"
+	"vicoin/network"
+)
+
+// A round of reconciliation asks for the hashes of at most maxReconcileBuckets differing buckets,
+// and fetches missing transactions in batches of syncBatchSize.
+const (
+	maxReconcileBuckets    = 32
+	reconcileMethodSummary = "reconcile.summary"
+	reconcileMethodHashes  = "reconcile.hashes"
+	reconcileMethodFetch   = "reconcile.fetch"
+	reconcileTimeout       = 30 * time.Second
+)
+
+// StartAntiEntropy reconciles the ledger with a random sync peer every interval, until Close,
+// so that transactions missed during a partition or a disconnect are eventually recovered.
+func (client *Client) StartAntiEntropy(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-client.stop:
+				return
+			case <-ticker.C:
+				peers := client.node.GetSyncPeers()
+				if len(peers) == 0 {
+					continue
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+				fetched, err := client.Reconcile(ctx, peers[rand.Intn(len(peers))])
+				cancel()
+				if err != nil {
+					log.Println("Unable to reconcile ledger: ", err)
+				} else if fetched > 0 {
+					log.Println("Recovered missing transactions: ", fetched)
+				}
+			}
+		}
+	}()
+}
+
+// Reconcile compares the summary of the ledger with that of peer, and fetches only the transactions
+// it has applied in the differing buckets that the ledger is missing. It returns how many were applied,
+// those spending funds the ledger still lacks once the rest are being kept in the mempool until they can be.
+// Transactions the ledger has and peer lacks are left for peer to fetch in its own rounds.
+func (client *Client) Reconcile(ctx context.Context, peer net.Addr) (int, error) {
+	rpc := client.node.RPC()
+	if rpc == nil {
+		return 0, ErrNoSyncPeers
+	}
+	remote, err := network.CallTyped[account.Summary](ctx, rpc, peer, reconcileMethodSummary, 0)
+	if err != nil {
+		return 0, err
+	}
+	buckets := client.ledger.Summary().Differences(remote)
+	if len(buckets) == 0 {
+		return 0, nil
+	}
+	if len(buckets) > maxReconcileBuckets {
+		rand.Shuffle(len(buckets), func(i, j int) { buckets[i], buckets[j] = buckets[j], buckets[i] })
+		buckets = buckets[:maxReconcileBuckets]
+	}
+	hashes, err := network.CallTyped[[]account.TransactionHash](ctx, rpc, peer, reconcileMethodHashes, buckets)
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[account.TransactionHash]bool)
+	for _, hash := range client.ledger.HashesIn(buckets) {
+		known[hash] = true
+	}
+	missing := make([]account.TransactionHash, 0)
+	for _, hash := range hashes {
+		if !known[hash] {
+			missing = append(missing, hash)
+		}
+	}
+	applied := 0
+	kept := make([]account.TransactionHash, 0)
+	for start := 0; start < len(missing); start += syncBatchSize {
+		end := start + syncBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		batch, err := network.CallTyped[[]account.SignedTransaction](ctx, rpc, peer, reconcileMethodFetch, missing[start:end])
+		if err != nil {
+			return applied, err
+		}
+		for i := range batch {
+			err := client.apply(&batch[i])
+			switch {
+			case err == nil:
+				applied++
+			case errors.Is(err, account.ErrInsufficientFunds):
+				client.keep(batch[i])
+				kept = append(kept, batch[i].Hash())
+			case !skippable(err):
+				return applied, fmt.Errorf("%s sent invalid transaction %s: %w", peer, batch[i].ID, err)
+			}
+		}
+	}
+	if len(kept) > 0 {
+		client.retryPending() // Those fetched ahead of their funding.
+		applied += len(client.ledger.Lookup(kept))
+	}
+	return applied, nil
+}
+
+// Internal
+
+// serveReconcile answers the reconciliation requests of other peers from the ledger.
+func (client *Client) serveReconcile(rpc *network.RPC) {
+	network.HandleTyped(rpc, reconcileMethodSummary, func(ctx context.Context, from net.Addr, _ int) (account.Summary, error) {
+		return client.ledger.Summary(), nil
+	})
+	network.HandleTyped(rpc, reconcileMethodHashes, func(ctx context.Context, from net.Addr, buckets []int) ([]account.TransactionHash, error) {
+		if len(buckets) > maxReconcileBuckets {
+			return nil, fmt.Errorf("at most %d buckets per request", maxReconcileBuckets)
+		}
+		return client.ledger.HashesIn(buckets), nil
+	})
+	network.HandleTyped(rpc, reconcileMethodFetch, func(ctx context.Context, from net.Addr, hashes []account.TransactionHash) ([]account.SignedTransaction, error) {
+		if len(hashes) > syncBatchSize {
+			return nil, fmt.Errorf("at most %d transactions per request", syncBatchSize)
+		}
+		return client.ledger.Lookup(hashes), nil
+	})
+}
@@ -6,13 +6,19 @@ import (
 	"net"
 	"strconv"
 	"sync"
+	"time"
 	"vicoin/crypto"
 	"vicoin/internal/account"
 	"vicoin/internal/node"
 )
 
+// Transactions pending are retried every pendingInterval, as those not valid yet may become so.
+const pendingInterval = 10 * time.Second
+
 type Client struct {
 	ledger               account.LedgerInterface
+	mempool              *account.Mempool
+	fees                 *account.FeeEstimator
 	node                 node.NodeInterface
 	internal             chan account.SignedTransaction
 	lock                 sync.Mutex
@@ -20,11 +26,24 @@ type Client struct {
 	account              string
 	public               *crypto.PublicKey
 	private              *crypto.PrivateKey
+	synced               bool
+	stop                 chan struct{}
+	stopOnce             sync.Once
 }
 
 func NewClient(ledger account.LedgerInterface, node node.NodeInterface, internal chan account.SignedTransaction) (*Client, error) {
+	mempool, err := account.NewMempool(account.DefaultMempoolCapacity)
+	if err != nil {
+		return nil, err
+	}
+	fees, err := account.NewFeeEstimator(account.DefaultFeeWindow)
+	if err != nil {
+		return nil, err
+	}
 	client := Client{
 		ledger:               ledger,
+		mempool:              mempool,
+		fees:                 fees,
 		node:                 node,
 		internal:             internal,
 		lock:                 sync.Mutex{},
@@ -32,33 +51,129 @@ func NewClient(ledger account.LedgerInterface, node node.NodeInterface, internal
 		account:              "",
 		public:               nil,
 		private:              nil,
+		synced:               false,
+		stop:                 make(chan struct{}),
+		stopOnce:             sync.Once{},
+	}
+	if rpc := node.RPC(); rpc != nil {
+		client.serveSync(rpc)
+		client.serveReconcile(rpc)
+		client.serveState(rpc)
+		client.serveDivergence(rpc)
 	}
 	go client.handle()
 	return &client, nil
 }
 
+// handle applies the transactions received, keeping those spending funds yet to arrive or not valid yet
+// in the mempool, and retrying those each transaction applied may unblock, as well as all of them every pendingInterval.
 func (client *Client) handle() {
+	ticker := time.NewTicker(pendingInterval)
+	defer ticker.Stop()
 	for {
-		transaction := <-client.internal
-		err := client.ledger.SignedTransaction(&transaction)
-		log.Println(err)
-		//TODO: Handle errors?
+		select {
+		case <-client.stop:
+			return
+		case <-ticker.C:
+			client.retryPending()
+		case transaction := <-client.internal:
+			err := client.admit(&transaction)
+			switch {
+			case err == nil:
+				client.applyPending(client.unblocked(transaction)...)
+			case errors.Is(err, account.ErrInsufficientFunds) || errors.Is(err, account.ErrNotYetValid):
+				client.keep(transaction)
+			default:
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// Transfer sends amount to to, paying fee for the transaction to be applied, see EstimateFee.
+func (client *Client) Transfer(amount float64, fee float64, to string) error {
+	return client.TransferWithin(amount, fee, to, 0, 0)
+}
+
+// TransferWithin sends a transfer only valid from notBefore until expiresAt, if set, see account.Transaction.
+// Transfers which aren't valid yet are refused, rather than kept until they are.
+func (client *Client) TransferWithin(amount float64, fee float64, to string, notBefore int64, expiresAt int64) error {
+	return client.submit(func(id string, from string, key *crypto.PrivateKey) (*account.SignedTransaction, error) {
+		return account.SignTransaction(account.Transaction{ID: id, From: from, To: to, Amount: amount, Fee: fee, NotBefore: notBefore, ExpiresAt: expiresAt}, key)
+	})
+}
+
+// TransferBatch pays every output under a single transaction, applied all or nothing, returning the result of each.
+func (client *Client) TransferBatch(outputs []account.Output, fee float64) ([]account.OutputResult, error) {
+	err := client.submit(func(id string, from string, key *crypto.PrivateKey) (*account.SignedTransaction, error) {
+		return account.NewSignedBatch(id, from, outputs, fee, key)
+	})
+	var batchErr *account.BatchError
+	if errors.As(err, &batchErr) {
+		return batchErr.Results, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	results := make([]account.OutputResult, 0, len(outputs))
+	for _, output := range outputs {
+		results = append(results, account.OutputResult{To: output.To, Amount: output.Amount, Err: nil})
 	}
+	return results, nil
+}
+
+// Issue creates amount for to, which the ledgers of the network only accept from one of the minters of the genesis.
+func (client *Client) Issue(amount float64, to string) error {
+	return client.submit(func(id string, from string, key *crypto.PrivateKey) (*account.SignedTransaction, error) {
+		return account.NewSignedIssuance(id, from, to, amount, key)
+	})
+}
+
+// Sign adds the signature of the credentials to a transaction from a multisig account they are one of the keys of.
+func (client *Client) Sign(transaction *account.SignedTransaction) error {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if client.private == nil || client.public == nil {
+		return errors.New("invalid credentials")
+	}
+	return transaction.AddSignature(client.public, client.private)
+}
+
+// Submit applies a transaction signed elsewhere, such as by the keys of a multisig account, to the ledger
+// before sending it to the network.
+func (client *Client) Submit(transaction *account.SignedTransaction) error {
+	err := client.admit(transaction)
+	if err != nil {
+		return err
+	}
+	client.node.SendTransaction(*transaction)
+	return nil
 }
 
-func (client *Client) Transfer(amount float64, to string) error {
+// EstimateFee returns the fee a transfer to to should pay, at the median fee rate of the transactions applied last.
+func (client *Client) EstimateFee(to string) float64 {
+	return client.estimateFee(account.SignedTransaction{To: to})
+}
+
+// EstimateBatchFee returns the fee a batch paying outputs should pay, see EstimateFee.
+func (client *Client) EstimateBatchFee(outputs []account.Output) float64 {
+	return client.estimateFee(account.SignedTransaction{Outputs: outputs})
+}
+
+// submit signs a transaction created by create, applying it to the ledger before sending it to the network.
+func (client *Client) submit(create func(id string, from string, key *crypto.PrivateKey) (*account.SignedTransaction, error)) error {
 	client.lock.Lock()
 	defer client.lock.Unlock()
 	if client.account == "" || client.private == nil || client.public == nil {
 		return errors.New("invalid credentials")
 	}
 	client.numberOfTransactions += 1
-	transaction, err := account.NewSignedTransaction(strconv.Itoa(client.numberOfTransactions), client.account, to, amount, client.private)
+	transaction, err := create(strconv.Itoa(client.numberOfTransactions), client.account, client.private)
 	if err != nil {
 		client.numberOfTransactions -= 1
 		return err
 	}
-	err = client.ledger.SignedTransaction(transaction)
+	err = client.admit(transaction)
 	if err != nil {
 		client.numberOfTransactions -= 1
 		return err
@@ -67,6 +182,87 @@ func (client *Client) Transfer(amount float64, to string) error {
 	return nil
 }
 
+// apply applies a transaction to the ledger, noting its fee rate for estimates.
+func (client *Client) apply(transaction *account.SignedTransaction) error {
+	if err := client.ledger.SignedTransaction(transaction); err != nil {
+		return err
+	}
+	client.fees.Record(transaction)
+	return nil
+}
+
+// admit applies a transaction received or submitted, within its validity window at the height of the ledger
+// and the local time, whatever admission it claims. Only the history of peers is applied at its admission.
+func (client *Client) admit(transaction *account.SignedTransaction) error {
+	transaction.Admitted = nil
+	return client.apply(transaction)
+}
+
+// keep adds a transaction to the mempool until it can be applied, unless dropped. Its signature is verified
+// once here, as the ledger checks funds first, so that those kept are cheap to retry.
+func (client *Client) keep(transaction account.SignedTransaction) {
+	if err := transaction.Verify(); err != nil {
+		log.Println("Transaction dropped: ", err)
+		return
+	}
+	if err := client.mempool.Add(transaction); err != nil {
+		log.Println("Transaction dropped: ", err)
+	}
+}
+
+// retryPending applies what it can of every transaction of the mempool.
+func (client *Client) retryPending() {
+	client.applyPending(client.mempool.Senders()...)
+}
+
+// applyPending applies the transactions of the mempool sent by senders, by decreasing fee rate, along with
+// those each applied unblocks in turn, rather than retrying every transaction pending.
+func (client *Client) applyPending(senders ...string) {
+	client.mempool.Expire(client.ledger.Height(), time.Now())
+	for len(senders) > 0 {
+		sender := senders[0]
+		senders = senders[1:]
+		for _, transaction := range client.mempool.PendingFrom(sender) {
+			err := client.apply(&transaction) // Those received were admitted, and those of the history kept their admission.
+			if errors.Is(err, account.ErrInsufficientFunds) || errors.Is(err, account.ErrNotYetValid) {
+				continue
+			}
+			client.mempool.Remove(transaction.Hash())
+			if err == nil {
+				senders = append(senders, client.unblocked(transaction)...)
+			}
+		}
+	}
+}
+
+// unblocked returns the senders whose transactions pending may be applicable once transaction was:
+// the accounts it paid, the fee collector included, and those waiting for the height it reached.
+func (client *Client) unblocked(transaction account.SignedTransaction) []string {
+	accounts := client.mempool.Ripe(client.ledger.Height(), time.Now())
+	if len(transaction.Outputs) == 0 {
+		accounts = append(accounts, transaction.To)
+	}
+	for _, output := range transaction.Outputs {
+		accounts = append(accounts, output.To)
+	}
+	if collector := client.ledger.Params().FeeCollector; collector != "" && transaction.Fee > 0 {
+		accounts = append(accounts, collector)
+	}
+	return accounts
+}
+
+// estimateFee fills in the sender, ID and a signature of the size the credentials make, to estimate the fee by size.
+func (client *Client) estimateFee(transaction account.SignedTransaction) float64 {
+	client.lock.Lock()
+	transaction.ID = strconv.Itoa(client.numberOfTransactions + 1)
+	transaction.From = client.account
+	if client.private != nil && client.private.N != nil {
+		transaction.Signature = string(make([]byte, (client.private.N.BitLen()+7)/8))
+	}
+	client.lock.Unlock()
+	return client.fees.Estimate(client.ledger.Params().MinFeeRate) * float64(transaction.Size())
+}
+
 func (client *Client) ProvideCredentials(public *crypto.PublicKey, private *crypto.PrivateKey) error {
 	client.lock.Lock()
 	defer client.lock.Unlock()
@@ -94,10 +290,15 @@ func (client *Client) GetPort() string {
 	return strconv.Itoa(client.node.GetAddr().(*net.TCPAddr).Port)
 }
 
+func (client *Client) GetAdvertisedAddrs() []net.Addr {
+	return client.node.GetAdvertisedAddrs()
+}
+
 func (client *Client) Connect(addr net.Addr) error {
 	return client.node.Connect(addr)
 }
 
 func (client *Client) Close() []error {
+	client.stopOnce.Do(func() { close(client.stop) })
 	return client.node.Close()
 }
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"vicoin/internal/account"
+	"vicoin/network"
+)
+
+const (
+	divergenceMethodDigest = "divergence.digest"
+	divergenceTimeout      = 30 * time.Second
+)
+
+// DivergenceReport describes how the ledger and that of Peer differ. Missing are the transactions Peer applied
+// and the ledger didn't, Extra those the ledger applied and Peer didn't. If there are neither, the balances
+// they started from differ.
+type DivergenceReport struct {
+	Peer    net.Addr
+	Time    time.Time
+	Local   account.StateDigest
+	Remote  account.StateDigest
+	Missing []account.SignedTransaction
+	Extra   []account.SignedTransaction
+}
+
+// StartDivergenceDetector compares the state of the ledger with that of a random sync peer every interval,
+// until Close. Divergences are logged, and reported in a file in dir unless it is empty.
+func (client *Client) StartDivergenceDetector(interval time.Duration, dir string) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-client.stop:
+				return
+			case <-ticker.C:
+				peers := client.node.GetSyncPeers()
+				if len(peers) == 0 {
+					continue
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), divergenceTimeout)
+				report, err := client.CheckDivergence(ctx, peers[rand.Intn(len(peers))])
+				cancel()
+				if err != nil {
+					log.Println("Unable to check ledger divergence: ", err)
+					continue
+				}
+				if report == nil {
+					continue
+				}
+				log.Println("Ledger diverged from peer: ", report.Peer)
+				if dir == "" {
+					continue
+				}
+				path, err := report.Write(dir)
+				if err != nil {
+					log.Println("Unable to write divergence report: ", err)
+					continue
+				}
+				log.Println("Divergence report written to: ", path)
+			}
+		}
+	}()
+}
+
+// CheckDivergence compares the state of the ledger with that of peer, returning nil if they agree.
+// Ledgers are only compared once at the same height, as they otherwise merely lag behind one another,
+// and then by the sets of transactions they applied, as honest ledgers may apply them in different orders.
+// Only the transactions in the first maxReconcileBuckets differing buckets of their summaries are reported.
+func (client *Client) CheckDivergence(ctx context.Context, peer net.Addr) (*DivergenceReport, error) {
+	rpc := client.node.RPC()
+	if rpc == nil {
+		return nil, ErrNoSyncPeers
+	}
+	remote, err := network.CallTyped[account.StateDigest](ctx, rpc, peer, divergenceMethodDigest, 0)
+	if err != nil {
+		return nil, err
+	}
+	local := client.ledger.Digest()
+	if local.Height != remote.Height || local.Root == remote.Root {
+		return nil, nil
+	}
+	report := &DivergenceReport{Peer: peer, Time: time.Now(), Local: local, Remote: remote, Missing: nil, Extra: nil}
+	summary, err := network.CallTyped[account.Summary](ctx, rpc, peer, reconcileMethodSummary, 0)
+	if err != nil {
+		return nil, err
+	}
+	buckets := client.ledger.Summary().Differences(summary)
+	if len(buckets) == 0 {
+		return report, nil
+	}
+	if len(buckets) > maxReconcileBuckets {
+		buckets = buckets[:maxReconcileBuckets]
+	}
+	hashes, err := network.CallTyped[[]account.TransactionHash](ctx, rpc, peer, reconcileMethodHashes, buckets)
+	if err != nil {
+		return nil, err
+	}
+	theirs := make(map[account.TransactionHash]bool)
+	for _, hash := range hashes {
+		theirs[hash] = true
+	}
+	ours := make(map[account.TransactionHash]bool)
+	extra := make([]account.TransactionHash, 0)
+	for _, hash := range client.ledger.HashesIn(buckets) {
+		ours[hash] = true
+		if !theirs[hash] {
+			extra = append(extra, hash)
+		}
+	}
+	missing := make([]account.TransactionHash, 0)
+	for _, hash := range hashes {
+		if !ours[hash] {
+			missing = append(missing, hash)
+		}
+	}
+	report.Extra = client.ledger.Lookup(extra)
+	for start := 0; start < len(missing); start += syncBatchSize {
+		end := start + syncBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		batch, err := network.CallTyped[[]account.SignedTransaction](ctx, rpc, peer, reconcileMethodFetch, missing[start:end])
+		if err != nil {
+			return nil, err
+		}
+		report.Missing = append(report.Missing, batch...)
+	}
+	return report, nil
+}
+
+// Write writes the report to a new file in dir, returning its path.
+func (report *DivergenceReport) Write(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("divergence-%s-%s.txt", report.Time.UTC().Format("20060102T150405.000"), strings.NewReplacer(":", "_", "[", "", "]", "").Replace(report.Peer.String()))
+	path := filepath.Join(dir, name)
+	return path, os.WriteFile(path, []byte(report.String()), 0644)
+}
+
+func (report *DivergenceReport) String() string {
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "Ledger diverged from %s at %s\n", report.Peer, report.Time.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&builder, "Local  : height %d, state root %x\n", report.Local.Height, report.Local.Root)
+	fmt.Fprintf(&builder, "Remote : height %d, state root %x\n", report.Remote.Height, report.Remote.Root)
+	if len(report.Missing) == 0 && len(report.Extra) == 0 {
+		fmt.Fprintf(&builder, "Transactions agree, so the balances they started from differ\n")
+		return builder.String()
+	}
+	fmt.Fprintf(&builder, "Missing %d transactions applied remotely\n", len(report.Missing))
+	for i := range report.Missing {
+		fmt.Fprintf(&builder, "  %s\n", describeTransaction(&report.Missing[i]))
+	}
+	fmt.Fprintf(&builder, "Extra %d transactions applied locally\n", len(report.Extra))
+	for i := range report.Extra {
+		fmt.Fprintf(&builder, "  %s\n", describeTransaction(&report.Extra[i]))
+	}
+	return builder.String()
+}
+
+// Internal
+
+// serveDivergence answers the divergence checks of other peers from the ledger.
+func (client *Client) serveDivergence(rpc *network.RPC) {
+	network.HandleTyped(rpc, divergenceMethodDigest, func(ctx context.Context, from net.Addr, _ int) (account.StateDigest, error) {
+		return client.ledger.Digest(), nil
+	})
+}
+
+func describeTransaction(transaction *account.SignedTransaction) string {
+	return fmt.Sprintf("%s of %f from %s to %s (hash %x)", transaction.ID, transaction.Amount, transaction.From, transaction.To, transaction.Hash())
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"vicoin/internal/account"
+	"vicoin/internal/node"
+	"vicoin/network"
+)
+
+const (
+	stateMethodRoot  = "state.root"
+	stateMethodProof = "state.proof"
+)
+
+var (
+	ErrNoQuorum     = errors.New("no state root reported by a quorum of peers")
+	ErrNoValidProof = errors.New("no peer proved the account under the trusted state root")
+)
+
+// LightClient looks up balances without holding a ledger. It trusts a state root once a quorum
+// of its sync peers report it, and a balance only once a peer proves it under that root.
+type LightClient struct {
+	node     node.NodeInterface
+	internal chan account.SignedTransaction
+	quorum   int
+	root     account.StateHash
+	lock     sync.Mutex
+}
+
+// NewLightClient drains the transactions received by node, as there is no ledger to apply them to.
+func NewLightClient(node node.NodeInterface, internal chan account.SignedTransaction, quorum int) *LightClient {
+	light := &LightClient{
+		node:     node,
+		internal: internal,
+		quorum:   quorum,
+		root:     account.StateHash{},
+		lock:     sync.Mutex{},
+	}
+	go light.handle()
+	return light
+}
+
+// TrackRoot asks every sync peer for its state root, trusting the one reported by the most peers
+// if they are at least a quorum.
+func (light *LightClient) TrackRoot(ctx context.Context) (account.StateHash, error) {
+	_, root, _, err := light.trackRoot(ctx)
+	return root, err
+}
+
+// Root returns the state root trusted last, the zero hash if none was yet.
+func (light *LightClient) Root() account.StateHash {
+	light.lock.Lock()
+	defer light.lock.Unlock()
+	return light.root
+}
+
+// GetBalance returns the balance of acc under a freshly trusted state root, zero for an account
+// proved absent. Proofs which don't verify against the root are discarded.
+func (light *LightClient) GetBalance(ctx context.Context, acc string) (float64, error) {
+	rpc, root, peers, err := light.trackRoot(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, peer := range peers {
+		proof, err := network.CallTyped[account.StateProof](ctx, rpc, peer, stateMethodProof, acc)
+		if err != nil {
+			log.Println("Unable to get state proof: ", err)
+			continue
+		}
+		if proof.Account != acc || proof.Verify(root) != nil {
+			log.Println("Invalid state proof from: ", peer)
+			continue
+		}
+		return proof.State.Balance, nil
+	}
+	return 0, ErrNoValidProof
+}
+
+func (light *LightClient) GetAdvertisedAddrs() []net.Addr {
+	return light.node.GetAdvertisedAddrs()
+}
+
+func (light *LightClient) Connect(addr net.Addr) error {
+	return light.node.Connect(addr)
+}
+
+func (light *LightClient) Close() []error {
+	return light.node.Close()
+}
+
+// Internal
+func (light *LightClient) handle() {
+	for range light.internal {
+	}
+}
+
+// trackRoot returns the state root trusted, along with the peers which reported it.
+func (light *LightClient) trackRoot(ctx context.Context) (*network.RPC, account.StateHash, []net.Addr, error) {
+	rpc := light.node.RPC()
+	if rpc == nil {
+		return nil, account.StateHash{}, nil, ErrNoSyncPeers
+	}
+	reporters := make(map[account.StateHash][]net.Addr)
+	for _, peer := range light.node.GetSyncPeers() {
+		root, err := network.CallTyped[account.StateHash](ctx, rpc, peer, stateMethodRoot, 0)
+		if err != nil {
+			log.Println("Unable to get state root of peer: ", err)
+			continue
+		}
+		reporters[root] = append(reporters[root], peer)
+	}
+	best, peers := account.StateHash{}, []net.Addr{}
+	for root, reported := range reporters {
+		if len(reported) > len(peers) {
+			best, peers = root, reported
+		}
+	}
+	if len(peers) == 0 || len(peers) < light.quorum {
+		return nil, account.StateHash{}, nil, fmt.Errorf("%w: %d of %d", ErrNoQuorum, len(peers), light.quorum)
+	}
+	light.lock.Lock()
+	light.root = best
+	light.lock.Unlock()
+	return rpc, best, peers, nil
+}
+
+// serveState answers the state root and proof requests of light clients from the ledger.
+func (client *Client) serveState(rpc *network.RPC) {
+	network.HandleTyped(rpc, stateMethodRoot, func(ctx context.Context, from net.Addr, _ int) (account.StateHash, error) {
+		return client.ledger.StateRoot(), nil
+	})
+	network.HandleTyped(rpc, stateMethodProof, func(ctx context.Context, from net.Addr, acc string) (account.StateProof, error) {
+		return client.ledger.Prove(acc), nil
+	})
+}
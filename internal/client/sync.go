@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"vicoin/internal/account"
+	"vicoin/network"
+)
+
+// Transactions are downloaded in batches of syncBatchSize.
+const (
+	syncBatchSize     = 500
+	syncMethodHeight  = "sync.height"
+	syncMethodHistory = "sync.history"
+)
+
+var (
+	ErrNoSyncPeers    = errors.New("no peers to sync from")
+	ErrSyncIncomplete = errors.New("history spends funds the ledger lacks")
+)
+
+// HistoryRequest asks for up to Limit transactions of the history of a peer, starting at the From'th.
+type HistoryRequest struct {
+	From  int
+	Limit int
+}
+
+// SyncProgress reports how many transactions of the history of Peer have been downloaded, of which
+// Pending spend funds the ledger lacks, and are kept in the mempool rather than applied.
+type SyncProgress struct {
+	Peer       net.Addr
+	Downloaded int
+	Pending    int
+	Target     int
+}
+
+// Sync downloads the transaction history of the peer furthest ahead, applying it to the ledger
+// and falling back to the next peer should one send invalid transactions or stop answering.
+// It returns once caught up to the tip of the peer, reporting each batch to progress, or with
+// ErrSyncIncomplete if transactions of the history still spend funds the ledger lacks by then.
+func (client *Client) Sync(ctx context.Context, progress func(SyncProgress)) error {
+	rpc := client.node.RPC()
+	if rpc == nil {
+		return ErrNoSyncPeers
+	}
+	type candidate struct {
+		addr   net.Addr
+		height int
+	}
+	candidates := make([]candidate, 0)
+	for _, peer := range client.node.GetSyncPeers() {
+		height, err := network.CallTyped[int](ctx, rpc, peer, syncMethodHeight, 0)
+		if err != nil {
+			log.Println("Unable to get height of peer: ", err)
+			continue
+		}
+		candidates = append(candidates, candidate{peer, height})
+	}
+	if len(candidates) == 0 {
+		return ErrNoSyncPeers
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].height > candidates[j].height
+	})
+	var err error
+	for _, candidate := range candidates {
+		err = client.syncFrom(ctx, rpc, candidate.addr, candidate.height, progress)
+		if err == nil {
+			client.lock.Lock()
+			client.synced = true
+			client.lock.Unlock()
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		log.Println("Unable to sync from peer: ", err)
+	}
+	return err
+}
+
+// Synced reports whether the ledger has caught up with the network since starting.
+func (client *Client) Synced() bool {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	return client.synced
+}
+
+// Internal
+
+// serveSync answers the sync requests of other peers from the ledger.
+func (client *Client) serveSync(rpc *network.RPC) {
+	network.HandleTyped(rpc, syncMethodHeight, func(ctx context.Context, from net.Addr, _ int) (int, error) {
+		return client.ledger.Height(), nil
+	})
+	network.HandleTyped(rpc, syncMethodHistory, func(ctx context.Context, from net.Addr, request HistoryRequest) ([]account.SignedTransaction, error) {
+		if request.Limit <= 0 || request.Limit > syncBatchSize {
+			request.Limit = syncBatchSize
+		}
+		return client.ledger.History(request.From, request.Limit), nil
+	})
+}
+
+func (client *Client) syncFrom(ctx context.Context, rpc *network.RPC, peer net.Addr, target int, progress func(SyncProgress)) error {
+	downloaded := 0
+	pending := make([]account.TransactionHash, 0)
+	for {
+		if downloaded >= target {
+			height, err := network.CallTyped[int](ctx, rpc, peer, syncMethodHeight, 0)
+			if err != nil {
+				return err
+			}
+			if height <= downloaded {
+				return client.settle(peer, pending) // Caught up.
+			}
+			target = height
+		}
+		batch, err := network.CallTyped[[]account.SignedTransaction](ctx, rpc, peer, syncMethodHistory, HistoryRequest{From: downloaded, Limit: syncBatchSize})
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return fmt.Errorf("%s stopped at %d of %d transactions", peer, downloaded, target)
+		}
+		for i := range batch {
+			err := client.apply(&batch[i])
+			switch {
+			case errors.Is(err, account.ErrInsufficientFunds):
+				client.keep(batch[i])
+				pending = append(pending, batch[i].Hash())
+			case err != nil && !skippable(err):
+				return fmt.Errorf("%s sent invalid transaction %s: %w", peer, batch[i].ID, err)
+			}
+		}
+		downloaded += len(batch)
+		if progress != nil {
+			progress(SyncProgress{Peer: peer, Downloaded: downloaded, Pending: len(pending), Target: target})
+		}
+	}
+}
+
+// settle applies what it can of the transactions of the history of peer left pending,
+// failing with ErrSyncIncomplete if any can't be.
+func (client *Client) settle(peer net.Addr, pending []account.TransactionHash) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	client.retryPending()
+	if applied := len(client.ledger.Lookup(pending)); applied < len(pending) {
+		return fmt.Errorf("%w: %d transactions of %s", ErrSyncIncomplete, len(pending)-applied, peer)
+	}
+	return nil
+}
+
+// skippable reports whether err is one a transaction of a valid history may fail with, as it was applied already.
+func skippable(err error) bool {
+	return errors.Is(err, account.ErrDuplicateTransaction)
+}
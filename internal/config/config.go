@@ -0,0 +1,167 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"vicoin/internal/account"
+	"vicoin/network"
+)
+
+type Config struct {
+	Listen    string   // Address to bind, host:port.
+	Advertise []string // Addresses peers are told to dial, host:port.
+	Gossip    network.GossipConfig
+	PeerBook  string   // File known peers are kept in, none if empty.
+	NodeKey   string   // File the key the node is identified by is kept in, a new one each start if empty.
+	Seeds     []string // Addresses dialed when no known peer is reachable, host:port.
+	Limits    network.ConnectionLimits
+	Light     bool   // Look up proven balances from full nodes instead of holding a ledger.
+	Quorum    int    // Sync peers which must agree on a state root for a light client to trust it.
+	Reports   string // Directory ledger divergence reports are written to, none if empty.
+	Genesis   string // JSON file defining the network, account.DefaultGenesis() if empty.
+}
+
+func Default() *Config {
+	return &Config{
+		Listen:    ":0",
+		Advertise: make([]string, 0),
+		Gossip:    network.DefaultGossipConfig,
+		PeerBook:  "peers.gob",
+		NodeKey:   "node.key",
+		Seeds:     make([]string, 0),
+		Limits:    network.DefaultConnectionLimits,
+		Light:     false,
+		Quorum:    2,
+		Reports:   "reports",
+		Genesis:   "",
+	}
+}
+
+// Parse reads a Config from command line arguments, falling back to Default for those missing.
+func Parse(args []string) (*Config, error) {
+	config := Default()
+	flags := flag.NewFlagSet("vicoin-client", flag.ContinueOnError)
+	flags.StringVar(&config.Listen, "listen", config.Listen, "address and port to listen on, e.g. 0.0.0.0:4000 or [::]:4000")
+	advertise := flags.String("advertise", "", "comma separated addresses to advertise to peers, e.g. 203.0.113.7:4000,[2001:db8::7]:4000")
+	flags.StringVar(&config.PeerBook, "peerbook", config.PeerBook, "file to remember known peers in across restarts, empty to forget them")
+	flags.StringVar(&config.NodeKey, "nodekey", config.NodeKey, "file to keep the node identity key in across restarts, empty for a new identity each start")
+	flags.IntVar(&config.Limits.MaxInbound, "max-inbound", config.Limits.MaxInbound, "maximum inbound connections, 0 for no limit")
+	flags.IntVar(&config.Limits.MaxOutbound, "max-outbound", config.Limits.MaxOutbound, "maximum outbound connections, 0 for no limit")
+	flags.IntVar(&config.Limits.MaxPerIP, "max-per-ip", config.Limits.MaxPerIP, "maximum connections per IP address, 0 for no limit")
+	flags.IntVar(&config.Limits.MaxPerSubnet, "max-per-subnet", config.Limits.MaxPerSubnet, "maximum connections per /24 or /64 subnet, 0 for no limit")
+	seeds := flags.String("seeds", "", "comma separated addresses of bootstrap nodes, e.g. seed.example.org:4000")
+	flags.IntVar(&config.Gossip.Fanout, "fanout", config.Gossip.Fanout, "peers each gossiped message is pushed to in full, 0 for all")
+	ttl := flags.Uint("gossip-ttl", uint(config.Gossip.TTL), "hops a published message travels, at most 255")
+	flags.BoolVar(&config.Gossip.LazyPush, "lazy-push", config.Gossip.LazyPush, "announce gossiped messages by ID to the peers not pushed to")
+	flags.BoolVar(&config.Light, "light", config.Light, "run as a light client, verifying balances proven by full nodes")
+	flags.IntVar(&config.Quorum, "quorum", config.Quorum, "sync peers which must agree on a state root for a light client to trust it")
+	flags.StringVar(&config.Genesis, "genesis", config.Genesis, "JSON file defining the allocations, minters and rules of the network")
+	flags.StringVar(&config.Reports, "reports", config.Reports, "directory to write ledger divergence reports to, empty to only log them")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if *ttl == 0 || *ttl > 255 {
+		return nil, fmt.Errorf("invalid gossip TTL %d, want 1 to 255", *ttl)
+	}
+	config.Gossip.TTL = uint8(*ttl)
+	if config.Limits.MaxInbound < 0 || config.Limits.MaxOutbound < 0 || config.Limits.MaxPerIP < 0 || config.Limits.MaxPerSubnet < 0 {
+		return nil, fmt.Errorf("invalid connection limits %v", config.Limits)
+	}
+	if config.Quorum < 1 {
+		return nil, fmt.Errorf("invalid quorum %d, want at least 1", config.Quorum)
+	}
+	if config.Gossip.Fanout < 0 {
+		return nil, fmt.Errorf("invalid fanout %d", config.Gossip.Fanout)
+	}
+	config.Advertise = splitList(*advertise)
+	config.Seeds = splitList(*seeds)
+	if _, err := net.ResolveTCPAddr("tcp", config.Listen); err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %w", config.Listen, err)
+	}
+	return config, nil
+}
+
+// AdvertisedAddrs resolves the addresses to advertise, defaulting to the address actually listened on.
+// Peers can't dial an unspecified IP, such as that of the default listen address, so the IP of an interface
+// is advertised instead, falling back to loopback with a warning when the host has none other.
+func (config *Config) AdvertisedAddrs(listening net.Addr) ([]net.Addr, error) {
+	if len(config.Advertise) == 0 {
+		tcp, ok := listening.(*net.TCPAddr)
+		if !ok || (tcp.IP != nil && !tcp.IP.IsUnspecified()) {
+			return []net.Addr{listening}, nil
+		}
+		return []net.Addr{&net.TCPAddr{IP: interfaceIP(), Port: tcp.Port}}, nil
+	}
+	addrs := make([]net.Addr, 0, len(config.Advertise))
+	for _, advertised := range config.Advertise {
+		addr, err := net.ResolveTCPAddr("tcp", advertised)
+		if err != nil {
+			return nil, fmt.Errorf("invalid advertised address %q: %w", advertised, err)
+		}
+		if addr.IP == nil || addr.IP.IsUnspecified() || addr.Port == 0 {
+			return nil, fmt.Errorf("advertised address %q must have a specific IP and port", advertised)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// LoadGenesis reads the genesis of the network, defaulting to account.DefaultGenesis().
+func (config *Config) LoadGenesis() (*account.Genesis, error) {
+	if config.Genesis == "" {
+		return account.DefaultGenesis(), nil
+	}
+	return account.LoadGenesis(config.Genesis)
+}
+
+// SeedAddrs resolves the seed addresses.
+func (config *Config) SeedAddrs() ([]net.Addr, error) {
+	addrs := make([]net.Addr, 0, len(config.Seeds))
+	for _, seed := range config.Seeds {
+		addr, err := net.ResolveTCPAddr("tcp", seed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed address %q: %w", seed, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// interfaceIP returns the first IP of an interface other than loopback, IPv4 first, or else loopback.
+func interfaceIP() net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		log.Println("Unable to list interface addresses: ", err)
+	}
+	var found net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP
+		}
+		if found == nil {
+			found = ipNet.IP
+		}
+	}
+	if found == nil {
+		log.Println("No interface address to advertise, only local peers will reach this node, see -advertise")
+		return net.IPv4(127, 0, 0, 1)
+	}
+	return found
+}
+
+func splitList(list string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -39,6 +39,5 @@ func FromB64(b64String string) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	object, _ := Deserialize(serializedObject)
-	return object, nil
+	return Deserialize(serializedObject)
 }
@@ -0,0 +1,61 @@
+package node
+
+import (
+	"log"
+	"net"
+	"time"
+)
+
+// Bootstrap dials up to bootstrapPeers addresses, the best known ones before the seeds.
+const bootstrapPeers = 8
+
+// SetPeerBook sets where peers are remembered, replacing the in-memory peer book the node starts with.
+func (node *Node) SetPeerBook(book *PeerBook) {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	node.book = book
+}
+
+func (node *Node) GetPeerBook() *PeerBook {
+	return node.getPeerBook()
+}
+
+// Ban bars the host of addr for duration, or forever if duration is zero, disconnecting it.
+func (node *Node) Ban(addr net.Addr, duration time.Duration) {
+	node.getPeerBook().Ban(addr, duration)
+	for _, conn := range node.socket.GetConnections() {
+		if hostOf(conn.RemoteAddr()) == hostOf(addr) {
+			node.disconnect(conn.RemoteAddr())
+		}
+	}
+}
+
+// Bootstrap connects to the peers remembered from earlier runs, falling back to the seeds,
+// returning the number of peers connected to.
+func (node *Node) Bootstrap(seeds []net.Addr) int {
+	candidates := node.getPeerBook().Candidates(bootstrapPeers)
+	for _, seed := range seeds {
+		if !containsAddr(candidates, seed) {
+			candidates = append(candidates, seed)
+		}
+	}
+	connected := 0
+	for _, addr := range candidates {
+		if connected == bootstrapPeers {
+			break
+		}
+		if err := node.Connect(addr); err != nil {
+			log.Println("Unable to bootstrap from peer: ", err)
+			continue
+		}
+		connected++
+	}
+	return connected
+}
+
+// Internal
+func (node *Node) getPeerBook() *PeerBook {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	return node.book
+}
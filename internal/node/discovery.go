@@ -0,0 +1,257 @@
+package node
+
+import (
+	"crypto/rand"
+	"errors"
+	"log"
+	"net"
+	"time"
+	"vicoin/network"
+)
+
+// Lookups query lookupConcurrency contacts at a time, waiting lookupTimeout for each to answer,
+// and dial at most maxContactsPerAnswer contacts not connected yet of each answer.
+const (
+	lookupConcurrency    = 3
+	lookupTimeout        = 5 * time.Second
+	maxContactsPerAnswer = 4
+)
+
+// SetNodeID sets the ID the node is known by, keeping the contacts known so far.
+func (node *Node) SetNodeID(id network.NodeID) {
+	routing := network.NewRoutingTable(id)
+	for _, contact := range node.getRouting().Contacts() {
+		routing.Add(contact)
+	}
+	node.lock.Lock()
+	node.routing = routing
+	node.lock.Unlock()
+}
+
+// StartDiscovery looks up the own ID right away, then a random one each interval, to fill the routing buckets.
+func (node *Node) StartDiscovery(interval time.Duration) {
+	go func() {
+		node.Lookup(node.getRouting().Self())
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				var target network.NodeID
+				rand.Read(target[:])
+				node.Lookup(target)
+			case <-node.stop:
+				return
+			}
+		}
+	}()
+}
+
+type lookupResult struct {
+	contact  network.Contact
+	found    []network.Contact
+	answered bool
+}
+
+// Lookup iteratively asks the closest known contacts for ones closer to target, until the
+// closest network.BucketSize found have all been asked. It returns those, closest first,
+// promoting those which answered to peers.
+func (node *Node) Lookup(target network.NodeID) []network.Contact {
+	self := node.getRouting().Self()
+	closest := node.getRouting().Closest(target, network.BucketSize)
+	queried := make(map[network.NodeID]bool)
+	answered := make(map[network.NodeID]bool)
+	for {
+		candidates := make([]network.Contact, 0, lookupConcurrency)
+		for _, contact := range closest {
+			if !queried[contact.ID] && len(candidates) < lookupConcurrency {
+				candidates = append(candidates, contact)
+				queried[contact.ID] = true
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		results := make(chan lookupResult, len(candidates))
+		for _, contact := range candidates {
+			go func(contact network.Contact) {
+				found, ok := node.findNode(contact, target)
+				results <- lookupResult{contact, found, ok}
+			}(contact)
+		}
+		for range candidates {
+			result := <-results
+			if result.answered {
+				answered[result.contact.ID] = true
+			}
+			fresh := make([]network.Contact, 0, len(result.found))
+			for _, found := range result.found {
+				if found.ID != self && !containsContact(closest, found.ID) && !containsContact(fresh, found.ID) {
+					fresh = append(fresh, found)
+				}
+			}
+			closest = append(closest, node.screen(result.contact.Addr, fresh)...)
+		}
+		network.SortByDistance(closest, target)
+		if len(closest) > network.BucketSize {
+			closest = closest[:network.BucketSize]
+		}
+	}
+	for _, contact := range closest {
+		if answered[contact.ID] {
+			node.addPeers(Peer{Addr: contact.Addr})
+		}
+	}
+	return closest
+}
+
+// Internal
+func (node *Node) getRouting() *network.RoutingTable {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	return node.routing
+}
+
+// findNode asks contact for the contacts it knows closest to target, dialing it if needed.
+func (node *Node) findNode(contact network.Contact, target network.NodeID) ([]network.Contact, bool) {
+	if node.getPeerBook().Banned(contact.Addr) {
+		return nil, false
+	}
+	conn := node.connectionTo(contact)
+	if conn == nil {
+		var err error
+		conn, err = node.socket.Connect(contact.Addr)
+		if err != nil {
+			if errors.Is(err, network.ErrConnectionLimit) {
+				return nil, false
+			}
+			log.Println("Unable to reach contact: ", err)
+			node.getRouting().Remove(contact.ID)
+			node.getPeerBook().Failed(contact.Addr)
+			return nil, false
+		}
+		node.getPeerBook().Succeeded(contact.Addr)
+		node.learn(conn.RemoteAddr())
+	}
+	if !network.Supports(conn, network.CapabilityDiscovery) {
+		return nil, false
+	}
+	key := conn.RemoteAddr().String()
+	replies := make(chan []network.Contact, 1)
+	node.lock.Lock()
+	if _, ok := node.lookups[key]; ok {
+		node.lock.Unlock()
+		return nil, false // Asked already by a concurrent lookup.
+	}
+	node.lookups[key] = replies
+	node.lock.Unlock()
+	defer func() {
+		node.lock.Lock()
+		delete(node.lookups, key)
+		node.lock.Unlock()
+	}()
+	err := node.socket.Send(network.Packet{Instruction: network.FindNode, Data: target}, conn.RemoteAddr())
+	if err != nil {
+		return nil, false
+	}
+	select {
+	case contacts := <-replies:
+		return contacts, true
+	case <-time.After(lookupTimeout):
+		node.getRouting().Remove(contact.ID)
+		return nil, false
+	case <-node.stop:
+		return nil, false
+	}
+}
+
+// screen returns the contacts found by origin worth querying. Those not dialable are dropped, and those beyond
+// maxContactsPerAnswer not connected yet or the rate of verifications of origin are left in the peer book,
+// so that peers can't have the node dial arbitrary hosts through lookups any more than through peer exchange.
+func (node *Node) screen(origin net.Addr, found []network.Contact) []network.Contact {
+	book := node.getPeerBook()
+	screened := make([]network.Contact, 0, len(found))
+	dials := 0
+	for _, contact := range found {
+		tcp, ok := contact.Addr.(*net.TCPAddr)
+		if !ok || validateAddr(tcp) != nil || !dialable(tcp) {
+			continue
+		}
+		if node.connectionTo(contact) != nil {
+			screened = append(screened, contact)
+			continue
+		}
+		book.Add(contact.Addr)
+		if dials >= maxContactsPerAnswer || !node.verifications.Allow(&net.IPAddr{IP: net.ParseIP(hostOf(origin))}, network.PexReply) {
+			continue
+		}
+		dials++
+		screened = append(screened, contact)
+	}
+	return screened
+}
+
+// connectionTo returns the open connection to the node with the ID of contact, if any.
+func (node *Node) connectionTo(contact network.Contact) net.Conn {
+	for _, conn := range node.socket.GetConnections() {
+		if hello, ok := network.RemoteHello(conn); ok && hello.RemoteHello().NodeID == contact.ID {
+			return conn
+		}
+	}
+	return nil
+}
+
+// learn adds the node at the other end of the connection to addr to the routing table, if it supports discovery.
+func (node *Node) learn(addr net.Addr) {
+	if addr == nil {
+		return
+	}
+	conn, ok := node.socket.GetConnections()[addr.String()]
+	if !ok || !network.Supports(conn, network.CapabilityDiscovery) {
+		return
+	}
+	hello, _ := network.RemoteHello(conn)
+	listening, err := net.ResolveTCPAddr("tcp", hello.RemoteHello().ListenAddr)
+	if err != nil || listening.IP == nil || listening.IP.IsUnspecified() {
+		return // Not dialable.
+	}
+	node.getRouting().Add(network.Contact{ID: hello.RemoteHello().NodeID, Addr: listening})
+}
+
+func (node *Node) handleFindNode(packet network.Packet, target network.NodeID) {
+	if packet.Origin() == nil {
+		return
+	}
+	node.learn(packet.Origin())
+	reply := network.Packet{
+		Instruction: network.Neighbours,
+		Data:        node.getRouting().Closest(target, network.BucketSize),
+	}
+	node.socket.Send(reply, packet.Origin())
+}
+
+func (node *Node) handleNeighbours(packet network.Packet, contacts []network.Contact) {
+	if packet.Origin() == nil {
+		return
+	}
+	node.learn(packet.Origin())
+	node.lock.Lock()
+	replies, ok := node.lookups[packet.Origin().String()]
+	node.lock.Unlock()
+	if !ok {
+		return // Unsolicited, or too late.
+	}
+	select {
+	case replies <- contacts:
+	default:
+	}
+}
+
+func containsContact(contacts []network.Contact, id network.NodeID) bool {
+	for _, contact := range contacts {
+		if contact.ID == id {
+			return true
+		}
+	}
+	return false
+}
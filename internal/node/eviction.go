@@ -0,0 +1,24 @@
+package node
+
+import (
+	"net"
+	"vicoin/network"
+)
+
+// evictionProtect is the number of peers protected for each of latency and usefulness.
+const evictionProtect = 4
+
+// Evict picks the inbound connection to close for a new one, protecting peers with low latency
+// and peers which recently sent new transactions or announcements, see network.ProtectingEviction.
+// Set it on the socket with SetEvictionStrategy.
+func (node *Node) Evict(candidates []network.PeerStats) (net.Addr, bool) {
+	node.lock.Lock()
+	for i, candidate := range candidates {
+		if tracked, ok := node.liveness[candidate.Addr.String()]; ok {
+			candidates[i].Latency = tracked.latency
+			candidates[i].LastUseful = tracked.lastUseful
+		}
+	}
+	node.lock.Unlock()
+	return network.ProtectingEviction{Protect: evictionProtect}.Evict(candidates)
+}
@@ -0,0 +1,182 @@
+package node
+
+import (
+	"log"
+	"net"
+	"time"
+	"vicoin/network"
+)
+
+// Heartbeat is the payload of Ping and Pong packets, a Pong echoing the nonce of its Ping.
+type Heartbeat struct {
+	Nonce uint64
+}
+
+// liveness tracks a single connection, keyed by its remote address.
+type liveness struct {
+	lastSeen   time.Time
+	lastUseful time.Time // Last time the remote sent a message not seen before.
+	pending    map[uint64]time.Time
+	latency    time.Duration
+	peer       string // Listening address of the remote, if known.
+}
+
+// StartHeartbeat pings every connection supporting it each interval, closing those silent for longer than timeout.
+// The peer book is saved along.
+func (node *Node) StartHeartbeat(interval time.Duration, timeout time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				node.beat(timeout)
+				if err := node.getPeerBook().Save(); err != nil {
+					log.Println("Error when saving peer book: ", err)
+				}
+			case <-node.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Ping sends a Ping to the connection with the remote address addr.
+func (node *Node) Ping(addr net.Addr) error {
+	node.lock.Lock()
+	node.nonce++
+	nonce := node.nonce
+	node.track(addr).pending[nonce] = time.Now()
+	node.lock.Unlock()
+	return node.socket.Send(network.Packet{Instruction: network.Ping, Data: Heartbeat{Nonce: nonce}}, addr)
+}
+
+// GetLatency returns the last measured round trip time to the connection with the remote address addr.
+func (node *Node) GetLatency(addr net.Addr) (time.Duration, bool) {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	tracked, ok := node.liveness[addr.String()]
+	if !ok || tracked.latency == 0 {
+		return 0, false
+	}
+	return tracked.latency, true
+}
+
+// Internal
+func (node *Node) beat(timeout time.Duration) {
+	for key, conn := range node.socket.GetConnections() {
+		if !network.Supports(conn, network.CapabilityHeartbeat) {
+			continue
+		}
+		node.lock.Lock()
+		tracked := node.track(conn.RemoteAddr())
+		if hello, ok := network.RemoteHello(conn); ok {
+			tracked.peer = hello.RemoteHello().ListenAddr
+		}
+		for nonce, sent := range tracked.pending {
+			if time.Since(sent) > timeout {
+				delete(tracked.pending, nonce)
+			}
+		}
+		silent := time.Since(tracked.lastSeen)
+		node.lock.Unlock()
+		if silent > timeout {
+			log.Println("Closing unresponsive net.Conn: ", key)
+			node.disconnect(conn.RemoteAddr())
+			continue
+		}
+		err := node.Ping(conn.RemoteAddr())
+		if err != nil {
+			log.Println("Error when pinging: ", err)
+		}
+	}
+}
+
+// dropped forgets what is kept about the connection to addr, once lost or closed.
+func (node *Node) dropped(addr net.Addr) {
+	node.limiter.Forget(addr)
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	delete(node.liveness, addr.String())
+}
+
+// disconnect closes the connection to addr, forgetting it as the socket only tells of connections lost.
+func (node *Node) disconnect(addr net.Addr) {
+	node.socket.Disconnect(addr)
+	node.dropped(addr)
+}
+
+// track returns the liveness of the connection to addr, creating it if unknown. Requires node.lock to be held.
+func (node *Node) track(addr net.Addr) *liveness {
+	tracked, ok := node.liveness[addr.String()]
+	if !ok {
+		tracked = &liveness{
+			lastSeen:   time.Now(),
+			lastUseful: time.Time{},
+			pending:    make(map[uint64]time.Time),
+			latency:    0,
+			peer:       addr.String(),
+		}
+		node.liveness[addr.String()] = tracked
+	}
+	return tracked
+}
+
+// alive marks the connection a packet was received on as alive.
+func (node *Node) alive(packet network.Packet) {
+	if packet.Origin() == nil {
+		return
+	}
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	node.track(packet.Origin()).lastSeen = time.Now()
+}
+
+// useful marks the connection a packet was received on as having sent something new.
+func (node *Node) useful(packet network.Packet) {
+	if packet.Origin() == nil {
+		return
+	}
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	node.track(packet.Origin()).lastUseful = time.Now()
+}
+
+func (node *Node) handlePing(packet network.Packet, heartbeat Heartbeat) {
+	if packet.Origin() == nil {
+		return
+	}
+	pong := network.Packet{
+		Instruction: network.Pong,
+		Data:        heartbeat,
+	}
+	node.socket.Send(pong, packet.Origin())
+}
+
+func (node *Node) handlePong(packet network.Packet, heartbeat Heartbeat) {
+	if packet.Origin() == nil {
+		return
+	}
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	tracked := node.track(packet.Origin())
+	sent, ok := tracked.pending[heartbeat.Nonce]
+	if !ok {
+		return // Unsolicited, or answered already.
+	}
+	delete(tracked.pending, heartbeat.Nonce)
+	tracked.latency = time.Since(sent)
+}
+
+// latencyOf returns the latency to the peer listening at addr, if it is connected and measured. Requires node.lock to be held.
+func (node *Node) latencyOf(addr net.Addr) time.Duration {
+	if addr == nil {
+		return 0
+	}
+	for key, tracked := range node.liveness {
+		if key == addr.String() || tracked.peer == addr.String() {
+			return tracked.latency
+		}
+	}
+	return 0
+}
@@ -3,6 +3,7 @@ package node
 import (
 	"net"
 	"vicoin/internal/account"
+	"vicoin/network"
 )
 
 type NodeInterface interface {
@@ -10,4 +11,7 @@ type NodeInterface interface {
 	Close() []error
 	SendTransaction(transaction account.SignedTransaction)
 	GetAddr() net.Addr
+	GetAdvertisedAddrs() []net.Addr
+	GetSyncPeers() []net.Addr
+	RPC() *network.RPC
 }
@@ -0,0 +1,190 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+	"vicoin/crypto"
+	"vicoin/internal/account"
+	"vicoin/network"
+)
+
+const maxPeersPerReply = 1000
+
+// Transactions which expired by time more than expiryGrace ago are refused, leaving room for the clocks of peers to differ.
+// Those expiring by height are left for clients to check against their ledger.
+const expiryGrace = 10 * time.Minute
+
+// Packets accepted from each peer per second, in total and per instruction. Instructions
+// answered with more work or traffic than they cost to send are limited the most.
+var (
+	peerRate         = network.Rate{PerSecond: 100, Burst: 200}
+	instructionRates = map[network.Insn]network.Rate{
+		network.PeerRequest:     {PerSecond: 0.2, Burst: 3},
+		network.PeerReply:       {PerSecond: 0.2, Burst: 3},
+		network.ConnAnnouncment: {PerSecond: 5, Burst: 20},
+		network.Transaction:     {PerSecond: 20, Burst: 50},
+		network.Ping:            {PerSecond: 1, Burst: 5},
+		network.Pong:            {PerSecond: 1, Burst: 5},
+		network.Inv:             {PerSecond: 20, Burst: 50},
+		network.GetData:         {PerSecond: 10, Burst: 20},
+		network.Data:            {PerSecond: 20, Burst: 50},
+		network.FindNode:        {PerSecond: 2, Burst: 10},
+		network.Neighbours:      {PerSecond: 2, Burst: 10},
+		network.PexRequest:      {PerSecond: 0.2, Burst: 3},
+		network.PexReply:        {PerSecond: 0.2, Burst: 3},
+		network.Request:         {PerSecond: 20, Burst: 50},
+		network.Response:        {PerSecond: 20, Burst: 50},
+	}
+)
+
+// newRegistry declares the payload of every instruction the node handles.
+func newRegistry() *network.Registry {
+	registry := network.NewRegistry()
+	registry.Register(network.PeerRequest, &net.TCPAddr{}, func(data interface{}) error {
+		return validateAddr(data.(*net.TCPAddr))
+	})
+	registry.Register(network.PeerReply, []Peer{}, func(data interface{}) error {
+		peers := data.([]Peer)
+		if len(peers) > maxPeersPerReply {
+			return fmt.Errorf("%d peers exceeds maximum of %d", len(peers), maxPeersPerReply)
+		}
+		for _, peer := range peers {
+			if err := validatePeer(peer); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	registry.Register(network.ConnAnnouncment, Peer{}, func(data interface{}) error {
+		return validatePeer(data.(Peer))
+	})
+	registry.Register(network.Transaction, account.SignedTransaction{}, func(data interface{}) error {
+		return validateTransaction(data.(account.SignedTransaction))
+	})
+	registry.Register(network.Ping, Heartbeat{}, nil)
+	registry.Register(network.Pong, Heartbeat{}, nil)
+	registry.Register(network.Inv, []network.MessageID{}, validateInventory)
+	registry.Register(network.GetData, []network.MessageID{}, validateInventory)
+	registry.Register(network.Data, network.Packet{}, func(data interface{}) error {
+		message := data.(network.Packet)
+		if !isGossip(message.Instruction) {
+			return fmt.Errorf("instruction %d isn't gossiped", message.Instruction)
+		}
+		return nil
+	})
+	registry.Register(network.FindNode, network.NodeID{}, nil)
+	registry.Register(network.Neighbours, []network.Contact{}, func(data interface{}) error {
+		contacts := data.([]network.Contact)
+		if len(contacts) > network.BucketSize {
+			return fmt.Errorf("%d contacts exceeds maximum of %d", len(contacts), network.BucketSize)
+		}
+		for _, contact := range contacts {
+			if err := validatePeer(Peer{Addr: contact.Addr}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	registry.Register(network.PexRequest, network.Announcement{}, func(data interface{}) error {
+		return data.(network.Announcement).Verify()
+	})
+	registry.Register(network.PexReply, PexReply{}, func(data interface{}) error {
+		reply := data.(PexReply)
+		if err := reply.Self.Verify(); err != nil {
+			return err
+		}
+		if len(reply.Addrs) > pexSampleSize {
+			return fmt.Errorf("%d addresses exceeds maximum of %d", len(reply.Addrs), pexSampleSize)
+		}
+		for _, received := range reply.Addrs {
+			if err := validatePeer(Peer{Addr: received.Addr}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	registry.Register(network.Request, network.RPCRequest{}, func(data interface{}) error {
+		method := data.(network.RPCRequest).Method
+		if method == "" || len(method) > network.MaxMethodLength {
+			return fmt.Errorf("invalid method %q", method)
+		}
+		return nil
+	})
+	registry.Register(network.Response, network.RPCResponse{}, nil)
+	return registry
+}
+
+func validatePeer(peer Peer) error {
+	addr, ok := peer.Addr.(*net.TCPAddr)
+	if !ok {
+		return fmt.Errorf("peer address of type %T, want *net.TCPAddr", peer.Addr)
+	}
+	return validateAddr(addr)
+}
+
+func validateAddr(addr *net.TCPAddr) error {
+	if addr == nil {
+		return errors.New("missing address")
+	}
+	if addr.Port < 1 || addr.Port > 65535 {
+		return fmt.Errorf("invalid port %d", addr.Port)
+	}
+	if addr.IP != nil && len(addr.IP) != net.IPv4len && len(addr.IP) != net.IPv6len {
+		return errors.New("invalid IP")
+	}
+	return nil
+}
+
+func validateTransaction(transaction account.SignedTransaction) error {
+	if transaction.ID == "" || transaction.From == "" {
+		return errors.New("transaction is missing ID or sender")
+	}
+	if transaction.Multisig != nil {
+		if err := transaction.Multisig.Validate(); err != nil {
+			return err
+		}
+		if transaction.Multisig.Account() != transaction.From {
+			return errors.New("multisig policy doesn't control the sender")
+		}
+		for _, key := range transaction.Multisig.Keys {
+			if _, err := new(crypto.PublicKey).FromString(key); err != nil {
+				return fmt.Errorf("invalid multisig key: %w", err)
+			}
+		}
+		if len(transaction.Signatures) != len(transaction.Multisig.Keys) {
+			return fmt.Errorf("%d signatures for %d keys", len(transaction.Signatures), len(transaction.Multisig.Keys))
+		}
+	} else if transaction.Signature == "" {
+		return errors.New("transaction is unsigned")
+	} else if _, err := new(crypto.PublicKey).FromString(transaction.From); err != nil {
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+	if err := account.ValidatePayments(&transaction); err != nil {
+		return err
+	}
+	if math.IsNaN(transaction.Fee) || math.IsInf(transaction.Fee, 0) || transaction.Fee < 0 {
+		return fmt.Errorf("invalid fee %f", transaction.Fee)
+	}
+	if transaction.NotBefore < 0 || transaction.ExpiresAt < 0 {
+		return errors.New("negative validity window")
+	}
+	sameUnit := (transaction.NotBefore < account.HeightThreshold) == (transaction.ExpiresAt < account.HeightThreshold)
+	if transaction.NotBefore > 0 && transaction.ExpiresAt > 0 && sameUnit && transaction.NotBefore >= transaction.ExpiresAt {
+		return errors.New("transaction expires before becoming valid")
+	}
+	if transaction.ExpiresAt >= account.HeightThreshold && time.Unix(transaction.ExpiresAt, 0).Add(expiryGrace).Before(time.Now()) {
+		return account.ErrExpired
+	}
+	return nil
+}
+
+func validateInventory(data interface{}) error {
+	ids := data.([]network.MessageID)
+	if len(ids) == 0 || len(ids) > network.MaxInventory {
+		return fmt.Errorf("inventory of %d messages, want 1 to %d", len(ids), network.MaxInventory)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package node
+
+import (
+	"errors"
+	"log"
+	"net"
+	"time"
+)
+
+// Penalties added to the misbehaviour score of a host, whose connection is closed once it reaches
+// disconnectScore and the host banned for banDuration. Scores are kept by host in the peer book rather
+// than by connection, as reconnecting from another port or after a restart would otherwise start over.
+const (
+	penaltyMalformed = 20
+	penaltyUnknown   = 5
+	penaltyFlood     = 2
+	disconnectScore  = 100
+	banDuration      = 24 * time.Hour
+)
+
+var errRateLimited = errors.New("rate limit exceeded")
+
+// penalize adds to the misbehaviour score of the host of origin, closing the connection once it reaches disconnectScore.
+// The host is banned then too, unless on loopback, which every local peer shares.
+func (node *Node) penalize(origin net.Addr, penalty int, reason error) {
+	if origin == nil {
+		return // Locally injected.
+	}
+	book := node.getPeerBook()
+	score := book.Penalize(origin, penalty)
+	log.Printf("Penalized %s by %d (score %d) : %v", origin, penalty, score, reason)
+	if score >= disconnectScore {
+		if loopback(origin) {
+			log.Println("Disconnecting misbehaving local peer: ", origin)
+		} else {
+			log.Println("Disconnecting and banning misbehaving peer: ", origin)
+			book.Ban(origin, banDuration)
+		}
+		node.disconnect(origin)
+		book.Forgive(origin)
+	}
+}
+
+// GetMisbehaviour returns the misbehaviour score of the host of addr.
+func (node *Node) GetMisbehaviour(addr net.Addr) int {
+	return node.getPeerBook().Misbehaviour(addr)
+}
+
+// Internal
+
+// loopback reports whether addr is a loopback address.
+func loopback(addr net.Addr) bool {
+	ip := net.ParseIP(hostOf(addr))
+	return ip != nil && ip.IsLoopback()
+}
@@ -1,64 +1,153 @@
 package node
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"math/rand"
 	"net"
 	"sync"
 	"vicoin/internal/account"
 	"vicoin/network"
 )
 
+// strengthenDials bounds the peers dialed at once to strengthen the network when no peer supports discovery.
+const strengthenDials = 10
+
 type Node struct {
-	peers    []Peer
-	history  map[network.Packet]bool
-	socket   network.Socket
-	internal chan interface{}
-	external chan account.SignedTransaction
-	lock     sync.Mutex
+	peers         []Peer
+	advertised    []net.Addr
+	registry      *network.Registry
+	limiter       *network.RateLimiter
+	book          *PeerBook
+	key           *network.NodeKey
+	verifying     map[string]bool
+	verifications *network.RateLimiter // Of addresses learned from each peer.
+	gossip        *network.Gossip
+	rpc           *network.RPC
+	routing       *network.RoutingTable
+	lookups       map[string]chan []network.Contact
+	socket        network.Socket
+	internal      chan interface{}
+	external      chan account.SignedTransaction
+	liveness      map[string]*liveness
+	nonce         uint64
+	stop          chan struct{}
+	stopOnce      sync.Once
+	lock          sync.Mutex
 }
 
 func NewNode(polysocket network.Socket, internalChannel chan interface{}, externalChannel chan account.SignedTransaction) (*Node, error) {
+	book, err := NewPeerBook("")
+	if err != nil {
+		return nil, err
+	}
 	node := &Node{
-		peers:    make([]Peer, 0),
-		history:  make(map[network.Packet]bool),
-		socket:   polysocket,
-		internal: internalChannel,
-		external: externalChannel,
-		lock:     sync.Mutex{},
+		peers:         make([]Peer, 0),
+		advertised:    []net.Addr{polysocket.GetAddr()},
+		registry:      newRegistry(),
+		limiter:       network.NewRateLimiter(peerRate, instructionRates),
+		book:          book,
+		key:           nil,
+		verifying:     make(map[string]bool),
+		verifications: network.NewRateLimiter(verifyRate, map[network.Insn]network.Rate{}),
+		gossip:        network.NewGossip(polysocket, network.DefaultGossipConfig),
+		rpc:           network.NewRPC(polysocket),
+		routing:       network.NewRoutingTable(network.NodeID{}),
+		lookups:       make(map[string]chan []network.Contact),
+		socket:        polysocket,
+		internal:      internalChannel,
+		external:      externalChannel,
+		liveness:      make(map[string]*liveness),
+		nonce:         0,
+		stop:          make(chan struct{}),
+		stopOnce:      sync.Once{},
+		lock:          sync.Mutex{},
 	}
 	self := Peer{
 		Addr: polysocket.GetAddr(),
 	}
 	node.peers = append(node.peers, self)
+	if notifier, ok := polysocket.(network.DropNotifier); ok {
+		notifier.OnDrop(node.dropped)
+	}
 	go node.handle()
 	return node, nil
 }
 
 func (node *Node) Connect(addr net.Addr) error {
-	go node.handle()
+	if node.getPeerBook().Banned(addr) {
+		return fmt.Errorf("%s is banned", addr)
+	}
 	conn, err := node.socket.Connect(addr)
 	if err != nil {
+		if !errors.Is(err, network.ErrConnectionLimit) {
+			node.getPeerBook().Failed(addr)
+		}
 		return err
 	}
+	node.getPeerBook().Succeeded(addr)
+	advertised := node.GetAdvertisedAddrs()
 	peerRequest := network.Packet{
 		Instruction: network.PeerRequest,
-		Data:        conn.LocalAddr(),
+		Data:        advertised[0],
 	}
 	node.socket.Send(peerRequest, conn.RemoteAddr())
-	connAnnouncemet := network.Packet{
-		Instruction: network.ConnAnnouncment,
-		Data:        node.socket.GetAddr(),
+	node.learn(conn.RemoteAddr())
+	node.requestPex(conn)
+	for _, addr := range advertised {
+		connAnnouncemet := network.Packet{
+			Instruction: network.ConnAnnouncment,
+			Data:        Peer{Addr: addr},
+		}
+		node.gossip.Publish(connAnnouncemet)
 	}
-	node.socket.Broadcast(connAnnouncemet)
 	return nil
 }
 
+// SetAdvertisedAddrs sets the addresses peers are told to reach the node at, replacing the listening address.
+func (node *Node) SetAdvertisedAddrs(addrs []net.Addr) {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	peers := make([]Peer, 0, len(node.peers))
+	for _, peer := range node.peers {
+		if !containsAddr(node.advertised, peer.Addr) {
+			peers = append(peers, peer)
+		}
+	}
+	for _, addr := range addrs {
+		peers = append(peers, Peer{Addr: addr})
+	}
+	node.peers = peers
+	node.advertised = addrs
+}
+
+func (node *Node) GetAdvertisedAddrs() []net.Addr {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	return append([]net.Addr{}, node.advertised...)
+}
+
 func (node *Node) Close() []error {
-	return node.socket.Close()
+	node.stopOnce.Do(func() {
+		close(node.stop)
+	})
+	errs := node.socket.Close()
+	if err := node.getPeerBook().Save(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
 }
 
 func (node *Node) GetPeers() []Peer {
-	return node.peers
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	peers := make([]Peer, len(node.peers))
+	for i, peer := range node.peers {
+		peers[i] = peer
+		peers[i].Latency = node.latencyOf(peer.Addr)
+	}
+	return peers
 }
 
 func (node *Node) GetAddr() net.Addr {
@@ -70,37 +159,12 @@ func (node *Node) handle() {
 		msg := <-node.internal
 		switch packet := msg.(type) {
 		case network.Packet:
-			if seen := node.history[msg.(network.Packet)]; seen {
+			node.alive(packet)
+			if packet.Origin() != nil && !node.limiter.Allow(packet.Origin(), packet.Instruction) {
+				node.penalize(packet.Origin(), penaltyFlood, errRateLimited)
 				continue
 			}
-			switch packet.Instruction {
-			// NOTE: Currently vulnerable to malformed packages, i.e. data not of expected type !!!!
-			case network.PeerRequest:
-				requester := packet.Data.(net.Addr)
-				node.lock.Lock()
-				reply := network.Packet{
-					Instruction: network.PeerReply,
-					Data:        node.peers,
-				}
-				node.socket.Send(reply, requester)
-				node.lock.Unlock()
-			case network.PeerReply:
-				peers := packet.Data.([]Peer)
-				node.lock.Lock()
-				node.peers = merge(peers, node.peers)
-				node.lock.Unlock()
-				node.strengthenNetwork()
-			case network.ConnAnnouncment:
-				peer := packet.Data.(Peer)
-				node.lock.Lock()
-				node.peers = append(node.peers, peer)
-				node.socket.Broadcast(msg)
-				node.lock.Unlock()
-			case network.Transaction:
-				signedTransaction := packet.Data.(account.SignedTransaction)
-				node.socket.Broadcast(msg)
-				node.external <- signedTransaction
-			}
+			node.receive(packet)
 		default:
 			log.Println("Unexpected message type, skipping")
 			continue
@@ -108,6 +172,80 @@ func (node *Node) handle() {
 	}
 }
 
+func (node *Node) receive(packet network.Packet) {
+	if packet.Origin() != nil && node.getPeerBook().Banned(packet.Origin()) {
+		node.disconnect(packet.Origin())
+		return
+	}
+	data, err := node.registry.Decode(packet)
+	if errors.Is(err, network.ErrUnknownInstruction) {
+		node.penalize(packet.Origin(), penaltyUnknown, err)
+		return
+	}
+	if err != nil {
+		node.penalize(packet.Origin(), penaltyMalformed, err)
+		return
+	}
+	if isGossip(packet.Instruction) {
+		if !node.gossip.Receive(packet) {
+			return // Seen already.
+		}
+		node.useful(packet)
+	}
+	switch packet.Instruction {
+	case network.PeerRequest:
+		requester := data.(*net.TCPAddr)
+		replyTo := packet.Origin()
+		if replyTo == nil {
+			replyTo = requester
+		}
+		reply := network.Packet{
+			Instruction: network.PeerReply,
+			Data:        node.samplePeers(pexSampleSize),
+		}
+		node.socket.Send(reply, replyTo)
+		node.discover(packet.Origin(), nil, requester)
+	case network.PeerReply:
+		for _, peer := range data.([]Peer) {
+			node.discover(packet.Origin(), nil, peer.Addr)
+		}
+		node.strengthenNetwork()
+	case network.ConnAnnouncment:
+		peer := data.(Peer)
+		node.discover(packet.Origin(), nil, peer.Addr)
+	case network.Transaction:
+		signedTransaction := data.(account.SignedTransaction)
+		node.external <- signedTransaction
+	case network.Ping:
+		node.handlePing(packet, data.(Heartbeat))
+	case network.Pong:
+		node.handlePong(packet, data.(Heartbeat))
+	case network.Inv:
+		node.gossip.HandleInv(packet, data.([]network.MessageID))
+	case network.GetData:
+		node.gossip.HandleGetData(packet, data.([]network.MessageID))
+	case network.Data:
+		message, requested := node.gossip.HandleData(packet, data.(network.Packet))
+		if requested {
+			node.receive(message)
+		}
+	case network.FindNode:
+		node.handleFindNode(packet, data.(network.NodeID))
+	case network.Neighbours:
+		node.handleNeighbours(packet, data.([]network.Contact))
+	case network.PexRequest:
+		node.handlePexRequest(packet, data.(network.Announcement))
+	case network.PexReply:
+		node.handlePexReply(packet, data.(PexReply))
+	case network.Request:
+		node.rpc.HandleRequest(packet, data.(network.RPCRequest))
+	case network.Response:
+		if !node.rpc.HandleResponse(packet, data.(network.RPCResponse)) {
+			log.Println("Dropping unexpected response from: ", packet.Origin())
+		}
+	}
+}
+
 func (node *Node) SendTransaction(transaction account.SignedTransaction) {
 	node.lock.Lock()
 	defer node.lock.Unlock()
@@ -115,36 +253,115 @@ func (node *Node) SendTransaction(transaction account.SignedTransaction) {
 		Instruction: network.Transaction,
 		Data:        transaction,
 	}
-	node.socket.Broadcast(wrappedTransaction)
+	node.gossip.Publish(wrappedTransaction)
 }
 
+// isGossip reports whether packets with the instruction are relayed through the network.
+func isGossip(instruction network.Insn) bool {
+	return instruction == network.Transaction || instruction == network.ConnAnnouncment
+}
+
+// GetSyncPeers returns the remote addresses of the connections able to answer requests.
+func (node *Node) GetSyncPeers() []net.Addr {
+	peers := make([]net.Addr, 0)
+	for _, conn := range node.socket.GetConnections() {
+		if network.Supports(conn, network.CapabilityRPC) {
+			peers = append(peers, conn.RemoteAddr())
+		}
+	}
+	return peers
+}
+
+// RPC returns the request/response layer of the node, for registering handlers and calling peers.
+func (node *Node) RPC() *network.RPC {
+	return node.rpc
+}
+
+// SetGossipConfig sets how packets are spread through the network.
+func (node *Node) SetGossipConfig(config network.GossipConfig) {
+	node.gossip.SetConfig(config)
+}
+
+// strengthenNetwork connects to more of the network, through a lookup of the own ID if
+// any peer supports discovery, or else by dialing the last strengthenDials received peers.
 func (node *Node) strengthenNetwork() {
-	node.lock.Lock()
-	defer node.lock.Unlock()
-	index := len(node.peers)
-	if len(node.peers) > 11 {
-		index = 11
+	if node.getRouting().Len() > 0 {
+		go node.Lookup(node.getRouting().Self())
+		return
 	}
-	for _, peer := range node.peers[len(node.peers)-index : len(node.peers)-1] {
-		node.socket.Connect(peer.Addr)
+	node.lock.Lock()
+	addrs := make([]net.Addr, 0, strengthenDials)
+	for i := len(node.peers) - 1; i >= 0 && len(addrs) < strengthenDials; i-- {
+		if !containsAddr(node.advertised, node.peers[i].Addr) {
+			addrs = append(addrs, node.peers[i].Addr)
+		}
 	}
+	node.lock.Unlock()
+	go func() {
+		for _, addr := range addrs {
+			if err := node.Connect(addr); err != nil {
+				log.Println("Failed to connect to peer: ", err)
+			}
+		}
+	}()
 }
 
 func contains(list []Peer, peer Peer) bool {
 	for _, known := range list {
-		if known == peer {
+		if sameAddr(known.Addr, peer.Addr) {
 			return true
 		}
 	}
 	return false
 }
 
-func merge(received []Peer, known []Peer) []Peer {
-	output := known
-	for _, addr := range received {
-		if !contains(output, addr) {
-			output = append(output, addr)
+func containsAddr(list []net.Addr, addr net.Addr) bool {
+	for _, known := range list {
+		if sameAddr(known, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameAddr(first net.Addr, second net.Addr) bool {
+	if first == nil || second == nil {
+		return first == second
+	}
+	return first.String() == second.String()
+}
+
+// samplePeers returns up to count random peers, other than the node itself.
+func (node *Node) samplePeers(count int) []Peer {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	peers := make([]Peer, 0, len(node.peers))
+	for _, peer := range node.peers {
+		if !containsAddr(node.advertised, peer.Addr) {
+			peers = append(peers, peer)
+		}
+	}
+	rand.Shuffle(len(peers), func(i, j int) {
+		peers[i], peers[j] = peers[j], peers[i]
+	})
+	if len(peers) > count {
+		peers = peers[:count]
+	}
+	return peers
+}
+
+// addPeers records peers in the peer book, and shares them while fewer than maxPeersPerReply are known.
+func (node *Node) addPeers(peers ...Peer) {
+	book := node.getPeerBook()
+	node.lock.Lock()
+	defer node.lock.Unlock()
+	for _, peer := range peers {
+		if containsAddr(node.advertised, peer.Addr) {
+			continue
+		}
+		book.Add(peer.Addr)
+		if !contains(node.peers, peer) && len(node.peers) < maxPeersPerReply {
+			node.peers = append(node.peers, peer)
 		}
 	}
-	return output
 }
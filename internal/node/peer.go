@@ -1,7 +1,11 @@
 package node
 
-import "net"
+import (
+	"net"
+	"time"
+)
 
 type Peer struct {
-	Addr net.Addr
+	Addr    net.Addr
+	Latency time.Duration // Last measured round trip time, zero if unknown.
 }
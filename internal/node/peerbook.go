@@ -0,0 +1,370 @@
+package node
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The peer book remembers at most maxRecords addresses, forgetting those least likely to be reachable first.
+const maxRecords = 10000
+
+// PeerRecord is what is known about a peer listening at Addr.
+type PeerRecord struct {
+	Addr         string
+	LastSeen     time.Time // Zero if never connected to.
+	Successes    int
+	Failures     int
+	Misbehaviour int // Of the host, shared by all its addresses.
+	index        int // In the eviction order.
+}
+
+// Verified reports whether the peer has ever been connected to.
+func (record PeerRecord) Verified() bool {
+	return record.Successes > 0
+}
+
+// Ban bars a host until Until, or forever if Permanent.
+type Ban struct {
+	Until     time.Time
+	Permanent bool
+}
+
+// PeerBook keeps known peer addresses, misbehaviour and bans, optionally persisted to a file so they survive restarts.
+// Records are kept of addresses peers listen at, misbehaviour and bans by host, as inbound connections come from arbitrary ports.
+type PeerBook struct {
+	path      string
+	records   map[string]*PeerRecord
+	eviction  evictionOrder
+	penalties map[string]int
+	bans      map[string]Ban
+	dirty     bool
+	lock      sync.Mutex
+}
+
+// peerBookFile is the persisted form of a PeerBook.
+type peerBookFile struct {
+	Records   []PeerRecord
+	Penalties map[string]int
+	Bans      map[string]Ban
+}
+
+// NewPeerBook loads the peer book persisted at path, starting empty if there is none. An empty path is never persisted.
+func NewPeerBook(path string) (*PeerBook, error) {
+	book := &PeerBook{
+		path:      path,
+		records:   make(map[string]*PeerRecord),
+		eviction:  make(evictionOrder, 0),
+		penalties: make(map[string]int),
+		bans:      make(map[string]Ban),
+		dirty:     false,
+		lock:      sync.Mutex{},
+	}
+	if path == "" {
+		return book, nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return book, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var stored peerBookFile
+	if err := gob.NewDecoder(file).Decode(&stored); err != nil {
+		return nil, err
+	}
+	for i := range stored.Records {
+		stored.Records[i].index = len(book.eviction)
+		book.records[stored.Records[i].Addr] = &stored.Records[i]
+		book.eviction = append(book.eviction, &stored.Records[i])
+	}
+	heap.Init(&book.eviction)
+	if stored.Penalties != nil {
+		book.penalties = stored.Penalties
+	}
+	if stored.Bans != nil {
+		book.bans = stored.Bans
+	}
+	return book, nil
+}
+
+// Add records addr as known, without having connected to it, unless it can't be dialed.
+func (book *PeerBook) Add(addr net.Addr) {
+	if !dialable(addr) {
+		return
+	}
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	book.record(addr)
+}
+
+// Succeeded records a successful connection to addr.
+func (book *PeerBook) Succeeded(addr net.Addr) {
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	record := book.record(addr)
+	record.Successes++
+	record.LastSeen = time.Now()
+	heap.Fix(&book.eviction, record.index)
+}
+
+// Failed records a failed attempt to connect to addr.
+func (book *PeerBook) Failed(addr net.Addr) {
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	record := book.record(addr)
+	record.Failures++
+	heap.Fix(&book.eviction, record.index)
+}
+
+// Penalize adds to the lasting misbehaviour score of the host of addr, returning the new score.
+func (book *PeerBook) Penalize(addr net.Addr, penalty int) int {
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	book.penalties[hostOf(addr)] += penalty
+	book.dirty = true
+	return book.penalties[hostOf(addr)]
+}
+
+// Misbehaviour returns the misbehaviour score of the host of addr.
+func (book *PeerBook) Misbehaviour(addr net.Addr) int {
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	return book.penalties[hostOf(addr)]
+}
+
+// Forgive clears the misbehaviour score of the host of addr, once acted upon.
+func (book *PeerBook) Forgive(addr net.Addr) {
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	delete(book.penalties, hostOf(addr))
+	book.dirty = true
+}
+
+// Get returns the record of addr, if known.
+func (book *PeerBook) Get(addr net.Addr) (PeerRecord, bool) {
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	record, ok := book.records[addr.String()]
+	if !ok {
+		return PeerRecord{}, false
+	}
+	found := *record
+	found.Misbehaviour = book.penalties[hostOf(addr)]
+	return found, true
+}
+
+// Ban bars the host of addr for duration, or forever if duration is zero.
+func (book *PeerBook) Ban(addr net.Addr, duration time.Duration) {
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	book.bans[hostOf(addr)] = Ban{Until: time.Now().Add(duration), Permanent: duration == 0}
+	book.dirty = true
+}
+
+func (book *PeerBook) Unban(addr net.Addr) {
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	delete(book.bans, hostOf(addr))
+	book.dirty = true
+}
+
+// Banned reports whether the host of addr is currently banned.
+func (book *PeerBook) Banned(addr net.Addr) bool {
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	ban, ok := book.bans[hostOf(addr)]
+	if !ok {
+		return false
+	}
+	if !ban.Permanent && time.Now().After(ban.Until) {
+		delete(book.bans, hostOf(addr))
+		book.dirty = true
+		return false
+	}
+	return true
+}
+
+// Candidates returns up to count addresses worth dialing, those verified and recently seen first.
+func (book *PeerBook) Candidates(count int) []net.Addr {
+	book.lock.Lock()
+	records := book.sorted()
+	book.lock.Unlock()
+	addrs := make([]net.Addr, 0, count)
+	for _, record := range records {
+		if len(addrs) == count {
+			break
+		}
+		addr, err := net.ResolveTCPAddr("tcp", record.Addr)
+		if err != nil || book.Banned(addr) {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// Sample returns up to count random verified records, seen within maxAge.
+func (book *PeerBook) Sample(count int, maxAge time.Duration) []PeerRecord {
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	records := make([]PeerRecord, 0)
+	for _, record := range book.records {
+		if record.Verified() && time.Since(record.LastSeen) <= maxAge {
+			records = append(records, *record)
+		}
+	}
+	rand.Shuffle(len(records), func(i, j int) {
+		records[i], records[j] = records[j], records[i]
+	})
+	if len(records) > count {
+		records = records[:count]
+	}
+	return records
+}
+
+func (book *PeerBook) Len() int {
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	return len(book.records)
+}
+
+// Save persists the peer book if it changed since it was last saved, replacing the file atomically.
+func (book *PeerBook) Save() error {
+	book.lock.Lock()
+	defer book.lock.Unlock()
+	if book.path == "" || !book.dirty {
+		return nil
+	}
+	stored := peerBookFile{
+		Records:   make([]PeerRecord, 0, len(book.records)),
+		Penalties: book.penalties,
+		Bans:      book.bans,
+	}
+	for _, record := range book.records {
+		stored.Records = append(stored.Records, *record)
+	}
+	file, err := os.CreateTemp(filepath.Dir(book.path), filepath.Base(book.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := gob.NewEncoder(file).Encode(stored); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), book.path); err != nil {
+		return err
+	}
+	book.dirty = false
+	return nil
+}
+
+// Internal
+
+// record returns the record of addr, creating it if unknown. Requires book.lock to be held.
+func (book *PeerBook) record(addr net.Addr) *PeerRecord {
+	book.dirty = true
+	record, ok := book.records[addr.String()]
+	if !ok {
+		if len(book.records) >= maxRecords {
+			worst := heap.Pop(&book.eviction).(*PeerRecord)
+			delete(book.records, worst.Addr)
+		}
+		record = &PeerRecord{Addr: addr.String()}
+		book.records[addr.String()] = record
+		heap.Push(&book.eviction, record)
+	}
+	return record
+}
+
+// sorted returns the records from most to least worth dialing. Requires book.lock to be held.
+func (book *PeerBook) sorted() []PeerRecord {
+	records := make([]PeerRecord, 0, len(book.records))
+	for _, record := range book.records {
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return better(&records[i], &records[j])
+	})
+	return records
+}
+
+// better reports whether record is more worth dialing than other.
+func better(record *PeerRecord, other *PeerRecord) bool {
+	if record.Verified() != other.Verified() {
+		return record.Verified()
+	}
+	if record.Successes-record.Failures != other.Successes-other.Failures {
+		return record.Successes-record.Failures > other.Successes-other.Failures
+	}
+	return record.LastSeen.After(other.LastSeen)
+}
+
+// evictionOrder is a heap of the records, the least worth dialing first.
+type evictionOrder []*PeerRecord
+
+func (order evictionOrder) Len() int {
+	return len(order)
+}
+
+func (order evictionOrder) Less(i, j int) bool {
+	return better(order[j], order[i])
+}
+
+func (order evictionOrder) Swap(i, j int) {
+	order[i], order[j] = order[j], order[i]
+	order[i].index = i
+	order[j].index = j
+}
+
+func (order *evictionOrder) Push(record interface{}) {
+	record.(*PeerRecord).index = len(*order)
+	*order = append(*order, record.(*PeerRecord))
+}
+
+func (order *evictionOrder) Pop() interface{} {
+	old := *order
+	record := old[len(old)-1]
+	*order = old[:len(old)-1]
+	return record
+}
+
+// dialable reports whether addr is a specific address a peer could be listening at.
+func dialable(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok || tcp == nil {
+		return false
+	}
+	return tcp.IP != nil && !tcp.IP.IsUnspecified() && !tcp.IP.IsMulticast() && tcp.Port > 0 && tcp.Port <= 65535
+}
+
+// hostOf returns the host of addr, or an empty string if addr is nil.
+func hostOf(addr net.Addr) string {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		if tcp == nil {
+			return ""
+		}
+		return tcp.IP.String()
+	}
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,197 @@
+package node
+
+import (
+	"errors"
+	"log"
+	"net"
+	"time"
+	"vicoin/network"
+)
+
+// Peer exchange replies carry at most pexSampleSize addresses, and at most maxVerifying
+// addresses are dialed at once to verify they are reachable before becoming peers.
+const (
+	pexSampleSize = 32
+	maxVerifying  = 8
+)
+
+// Addresses learned from a peer are dialed to verify them at most at verifyRate per host of the peer,
+// so that peers can't have the node dial addresses of their choosing at will.
+var verifyRate = network.Rate{PerSecond: 0.1, Burst: 8}
+
+// PexAddr is an address passed on in peer exchange, along with when it was last connected to.
+type PexAddr struct {
+	Addr net.Addr
+	Seen int64 // Unix seconds.
+}
+
+// PexReply answers a PexRequest with the announcement of the replying node and a sample of the peers it knows.
+type PexReply struct {
+	Self  network.Announcement
+	Addrs []PexAddr
+}
+
+// SetNodeKey sets the key the node signs its announcements with, and the ID derived from it.
+func (node *Node) SetNodeKey(key *network.NodeKey) {
+	node.lock.Lock()
+	node.key = key
+	node.lock.Unlock()
+	node.SetNodeID(key.ID())
+}
+
+// Internal
+
+// requestPex announces the node to the remote of conn, asking for addresses in return.
+func (node *Node) requestPex(conn net.Conn) {
+	announcement, ok := node.announcement()
+	if !ok || !network.Supports(conn, network.CapabilityPex) {
+		return
+	}
+	node.socket.Send(network.Packet{Instruction: network.PexRequest, Data: announcement}, conn.RemoteAddr())
+}
+
+// announcement returns the signed announcement of the advertised addresses, if the node has a key.
+func (node *Node) announcement() (network.Announcement, bool) {
+	node.lock.Lock()
+	key := node.key
+	addrs := node.advertised
+	node.lock.Unlock()
+	if key == nil {
+		return network.Announcement{}, false
+	}
+	if len(addrs) > network.MaxAnnouncedAddrs {
+		addrs = addrs[:network.MaxAnnouncedAddrs]
+	}
+	return network.NewAnnouncement(key, addrs), true
+}
+
+func (node *Node) handlePexRequest(packet network.Packet, announcement network.Announcement) {
+	if packet.Origin() == nil {
+		return
+	}
+	if !node.announcedBy(packet.Origin(), announcement) {
+		node.penalize(packet.Origin(), penaltyMalformed, errors.New("announcement of another node"))
+		return
+	}
+	node.verifyAnnounced(packet.Origin(), announcement)
+	own, ok := node.announcement()
+	if !ok {
+		return
+	}
+	reply := PexReply{
+		Self:  own,
+		Addrs: make([]PexAddr, 0),
+	}
+	for _, record := range node.getPeerBook().Sample(pexSampleSize, network.MaxAnnouncementAge) {
+		addr, err := net.ResolveTCPAddr("tcp", record.Addr)
+		if err == nil && !sameAddr(addr, packet.Origin()) {
+			reply.Addrs = append(reply.Addrs, PexAddr{Addr: addr, Seen: record.LastSeen.Unix()})
+		}
+	}
+	node.socket.Send(network.Packet{Instruction: network.PexReply, Data: reply}, packet.Origin())
+}
+
+func (node *Node) handlePexReply(packet network.Packet, reply PexReply) {
+	if packet.Origin() == nil {
+		return
+	}
+	if !node.announcedBy(packet.Origin(), reply.Self) {
+		node.penalize(packet.Origin(), penaltyMalformed, errors.New("announcement of another node"))
+		return
+	}
+	node.verifyAnnounced(packet.Origin(), reply.Self)
+	for _, received := range reply.Addrs {
+		seen := time.Unix(received.Seen, 0)
+		if time.Since(seen) > network.MaxAnnouncementAge || time.Until(seen) > time.Minute {
+			continue // Stale, or dated ahead.
+		}
+		node.discover(packet.Origin(), nil, received.Addr)
+	}
+}
+
+// announcedBy reports whether announcement is by the remote of the connection to origin, as far as that can be
+// verified. Announcements over connections which are unknown, dropped since for instance, are refused.
+func (node *Node) announcedBy(origin net.Addr, announcement network.Announcement) bool {
+	conn, ok := node.socket.GetConnections()[origin.String()]
+	if !ok {
+		return false
+	}
+	if identity, ok := network.RemoteIdentity(conn); ok {
+		return identity.Equal(announcement.Identity)
+	}
+	if hello, ok := network.RemoteHello(conn); ok {
+		return hello.RemoteHello().NodeID == announcement.ID()
+	}
+	return true
+}
+
+func (node *Node) verifyAnnounced(origin net.Addr, announcement network.Announcement) {
+	id := announcement.ID()
+	for _, announced := range announcement.Addrs {
+		addr, err := net.ResolveTCPAddr("tcp", announced)
+		if err != nil {
+			continue
+		}
+		node.discover(origin, &id, addr)
+	}
+}
+
+// discover records addr, learned from the peer at origin, verifying it unless it is invalid or known already.
+// Addresses beyond the rate of verifications of the peer are left in the peer book, to be dialed later on.
+func (node *Node) discover(origin net.Addr, id *network.NodeID, addr net.Addr) {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok || validateAddr(tcp) != nil || !dialable(tcp) {
+		return
+	}
+	book := node.getPeerBook()
+	if _, known := book.Get(addr); known {
+		return
+	}
+	book.Add(addr)
+	if origin != nil && !node.verifications.Allow(&net.IPAddr{IP: net.ParseIP(hostOf(origin))}, network.PexReply) {
+		return
+	}
+	node.verify(id, addr)
+}
+
+// verify dials addr in the background, promoting it to a peer once reachable, and if id is given,
+// found to be the node with that ID. Addresses are skipped while maxVerifying others are dialed.
+func (node *Node) verify(id *network.NodeID, addr net.Addr) {
+	book := node.getPeerBook()
+	if book.Banned(addr) {
+		return
+	}
+	node.lock.Lock()
+	if containsAddr(node.advertised, addr) || contains(node.peers, Peer{Addr: addr}) || node.verifying[addr.String()] || len(node.verifying) >= maxVerifying {
+		node.lock.Unlock()
+		return
+	}
+	node.verifying[addr.String()] = true
+	node.lock.Unlock()
+	go func() {
+		defer func() {
+			node.lock.Lock()
+			delete(node.verifying, addr.String())
+			node.lock.Unlock()
+		}()
+		conn, ok := node.socket.GetConnections()[addr.String()]
+		if !ok {
+			var err error
+			conn, err = node.socket.Connect(addr)
+			if errors.Is(err, network.ErrConnectionLimit) {
+				return // Left in the peer book for later.
+			}
+			if err != nil {
+				book.Failed(addr)
+				return
+			}
+		}
+		if hello, ok := network.RemoteHello(conn); ok && id != nil && hello.RemoteHello().NodeID != *id {
+			log.Println("Announced address belongs to another node: ", addr)
+			book.Failed(addr)
+			return
+		}
+		book.Succeeded(addr)
+		node.addPeers(Peer{Addr: addr})
+	}()
+}
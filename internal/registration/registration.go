@@ -2,8 +2,12 @@ package registration
 
 import (
 	"encoding/gob"
+	"net"
 	"vicoin/crypto"
 	"vicoin/internal/account"
+	"vicoin/internal/client"
+	"vicoin/internal/node"
+	"vicoin/network"
 )
 
 func RegisterStructsWithGob() {
@@ -11,4 +15,25 @@ func RegisterStructsWithGob() {
 	gob.Register(account.Transaction{})
 	gob.Register(crypto.PrivateKey{})
 	gob.Register(crypto.PublicKey{})
+	gob.Register(node.Heartbeat{})
+	gob.Register(node.Peer{})
+	gob.Register([]node.Peer{})
+	gob.Register(&net.TCPAddr{})
+	gob.Register([]network.MessageID{})
+	gob.Register(network.Packet{})
+	gob.Register(network.NodeID{})
+	gob.Register([]network.Contact{})
+	gob.Register(network.Announcement{})
+	gob.Register(node.PexReply{})
+	gob.Register(network.RPCRequest{})
+	gob.Register(network.RPCResponse{})
+	gob.Register(client.HistoryRequest{})
+	gob.Register([]account.SignedTransaction{})
+	gob.Register(account.Summary{})
+	gob.Register([]account.TransactionHash{})
+	gob.Register([]int{})
+	gob.Register(account.StateHash{})
+	gob.Register(account.StateProof{})
+	gob.Register(account.StateDigest{})
+	gob.Register(account.TransactionHash{})
 }
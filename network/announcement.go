@@ -0,0 +1,71 @@
+package network
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Announcements older than MaxAnnouncementAge, or dated more than maxClockSkew ahead, are refused.
+const (
+	MaxAnnouncementAge = 3 * time.Hour
+	MaxAnnouncedAddrs  = 4
+	maxClockSkew       = 10 * time.Minute
+	announcementPrefix = "vicoin-announcement:"
+)
+
+// Announcement is a node stating the addresses it listens at, signed with its identity key
+// so other nodes can pass it on without being able to alter it.
+type Announcement struct {
+	Identity  ed25519.PublicKey
+	Addrs     []string
+	Timestamp int64 // Unix seconds.
+	Signature []byte
+}
+
+func NewAnnouncement(key *NodeKey, addrs []net.Addr) Announcement {
+	announcement := Announcement{
+		Identity:  key.Identity(),
+		Addrs:     make([]string, 0, len(addrs)),
+		Timestamp: time.Now().Unix(),
+	}
+	for _, addr := range addrs {
+		announcement.Addrs = append(announcement.Addrs, addr.String())
+	}
+	announcement.Signature = ed25519.Sign(key.identity, announcement.signed())
+	return announcement
+}
+
+// ID returns the ID of the announcing node.
+func (announcement Announcement) ID() NodeID {
+	return IDFromIdentity(announcement.Identity)
+}
+
+// Verify checks the signature and age of the announcement.
+func (announcement Announcement) Verify() error {
+	if len(announcement.Identity) != ed25519.PublicKeySize {
+		return errors.New("invalid identity")
+	}
+	if len(announcement.Addrs) == 0 || len(announcement.Addrs) > MaxAnnouncedAddrs {
+		return fmt.Errorf("%d addresses, want 1 to %d", len(announcement.Addrs), MaxAnnouncedAddrs)
+	}
+	signedAt := time.Unix(announcement.Timestamp, 0)
+	if time.Since(signedAt) > MaxAnnouncementAge || time.Until(signedAt) > maxClockSkew {
+		return fmt.Errorf("announcement dated %s", signedAt)
+	}
+	if !ed25519.Verify(announcement.Identity, announcement.signed(), announcement.Signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// Internal
+func (announcement Announcement) signed() []byte {
+	signed := []byte(announcementPrefix)
+	signed = binary.BigEndian.AppendUint64(signed, uint64(announcement.Timestamp))
+	return append(signed, strings.Join(announcement.Addrs, ",")...)
+}
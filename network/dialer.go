@@ -8,8 +8,8 @@ func NewTCPDialer() (*TCPDialer, error) {
 	return &TCPDialer{}, nil
 }
 
+// Dial leaves the local address to the system, so both IPv4 and IPv6 addresses can be dialed.
 func (dialer *TCPDialer) Dial(addr net.Addr) (net.Conn, error) {
-	local, _ := net.ResolveTCPAddr("tcp", "0.0.0.0:0")
-	socket, err := net.DialTCP("tcp", local, addr.(*net.TCPAddr))
+	socket, err := net.DialTCP("tcp", nil, addr.(*net.TCPAddr))
 	return socket, err
 }
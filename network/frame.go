@@ -0,0 +1,34 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const maxFrameSize = 65535
+
+// Frames are prefixed with their length as a big endian uint16.
+func writeFrame(writer io.Writer, frame []byte) error {
+	if len(frame) > maxFrameSize {
+		return errors.New("frame exceeds maximum size")
+	}
+	var buffer bytes.Buffer
+	binary.Write(&buffer, binary.BigEndian, uint16(len(frame)))
+	buffer.Write(frame)
+	_, err := writer.Write(buffer.Bytes())
+	return err
+}
+
+func readFrame(reader io.Reader) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
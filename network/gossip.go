@@ -0,0 +1,251 @@
+package network
+
+import (
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+type GossipConfig struct {
+	Fanout         int           // Peers a message is pushed to in full, all of them if zero.
+	TTL            uint8         // Hops a published message travels.
+	LazyPush       bool          // Announce messages to the remaining peers by ID, letting them request what they lack.
+	RequestTimeout time.Duration // Wait for a requested message before asking another announcer.
+}
+
+var DefaultGossipConfig = GossipConfig{
+	Fanout:         6,
+	TTL:            8,
+	LazyPush:       true,
+	RequestTimeout: 5 * time.Second,
+}
+
+// Gossiped messages are relayed once, remembered for seenTTL or until seenCapacity newer ones
+// are seen. The last storeCapacity of them are kept to answer GetData.
+const (
+	seenCapacity  = 10000
+	seenTTL       = 10 * time.Minute
+	storeCapacity = 1000
+	MaxInventory  = 1000 // Message IDs in a single Inv or GetData.
+
+	maxRequests   = 10000 // Messages requested and not received yet.
+	maxAnnouncers = 8     // Peers remembered per requested message to retry with.
+)
+
+// request is a message asked for with GetData, along with the other peers which announced it.
+type request struct {
+	asked      net.Addr
+	announcers []net.Addr // Not asked yet, oldest first.
+	attempts   int
+}
+
+// Gossip spreads packets epidemically, pushing each to a random subset of the connections.
+type Gossip struct {
+	socket Socket
+	config GossipConfig
+	seen   *SeenCache
+	store  map[MessageID]Packet
+	stored []MessageID // Oldest first.
+	wanted map[MessageID]*request
+	lock   sync.Mutex
+}
+
+func NewGossip(socket Socket, config GossipConfig) *Gossip {
+	return &Gossip{
+		socket: socket,
+		config: config,
+		seen:   NewSeenCache(seenCapacity, seenTTL),
+		store:  make(map[MessageID]Packet),
+		stored: make([]MessageID, 0),
+		wanted: make(map[MessageID]*request),
+		lock:   sync.Mutex{},
+	}
+}
+
+func (gossip *Gossip) SetConfig(config GossipConfig) {
+	gossip.lock.Lock()
+	defer gossip.lock.Unlock()
+	gossip.config = config
+}
+
+// Publish originates packet, sending it to the network.
+func (gossip *Gossip) Publish(packet Packet) error {
+	gossip.lock.Lock()
+	packet.TTL = gossip.config.TTL
+	gossip.lock.Unlock()
+	id, err := NewMessageID(packet)
+	if err != nil {
+		return err
+	}
+	gossip.seen.Observe(id)
+	gossip.spread(id, packet)
+	return nil
+}
+
+// Receive records a gossiped packet, relaying it onwards if its TTL allows.
+// It reports whether the packet is new, those seen before should be ignored.
+func (gossip *Gossip) Receive(packet Packet) bool {
+	id, err := NewMessageID(packet)
+	if err != nil {
+		log.Println("Unable to identify packet: ", err)
+		return false
+	}
+	if gossip.seen.Observe(id) {
+		return false
+	}
+	if packet.TTL > 1 {
+		packet.TTL--
+		gossip.spread(id, packet)
+	}
+	return true
+}
+
+// HandleInv requests the announced messages not seen yet from the announcer. Messages
+// requested from another peer already are only asked from this one should that time out.
+func (gossip *Gossip) HandleInv(packet Packet, ids []MessageID) {
+	if packet.Origin() == nil {
+		return
+	}
+	missing := make([]MessageID, 0)
+	gossip.lock.Lock()
+	for _, id := range ids {
+		if gossip.seen.Contains(id) {
+			continue
+		}
+		if wanted, ok := gossip.wanted[id]; ok {
+			if len(wanted.announcers) < maxAnnouncers {
+				wanted.announcers = append(wanted.announcers, packet.Origin())
+			}
+			continue
+		}
+		if len(gossip.wanted) >= maxRequests {
+			continue
+		}
+		gossip.wanted[id] = &request{asked: packet.Origin(), announcers: make([]net.Addr, 0), attempts: 1}
+		gossip.expect(id, 1)
+		missing = append(missing, id)
+	}
+	gossip.lock.Unlock()
+	if len(missing) == 0 {
+		return
+	}
+	gossip.socket.Send(Packet{Instruction: GetData, Data: missing}, packet.Origin())
+}
+
+// HandleGetData sends the requested messages still stored to the requester, each wrapped in a Data packet.
+func (gossip *Gossip) HandleGetData(packet Packet, ids []MessageID) {
+	if packet.Origin() == nil {
+		return
+	}
+	for _, id := range ids {
+		gossip.lock.Lock()
+		stored, ok := gossip.store[id]
+		gossip.lock.Unlock()
+		if ok {
+			gossip.socket.Send(Packet{Instruction: Data, Data: stored}, packet.Origin())
+		}
+	}
+}
+
+// HandleData returns the message carried by a Data packet, reporting whether it was requested.
+// The message should then be handled as if received by itself, unrequested ones ignored.
+func (gossip *Gossip) HandleData(packet Packet, message Packet) (Packet, bool) {
+	id, err := NewMessageID(message)
+	if err != nil {
+		return Packet{}, false
+	}
+	gossip.lock.Lock()
+	_, ok := gossip.wanted[id]
+	delete(gossip.wanted, id)
+	gossip.lock.Unlock()
+	return message.WithOrigin(packet.Origin()), ok
+}
+
+// Internal
+func (gossip *Gossip) spread(id MessageID, packet Packet) {
+	gossip.lock.Lock()
+	config := gossip.config
+	gossip.remember(id, packet)
+	gossip.lock.Unlock()
+	targets := make([]net.Conn, 0)
+	for _, conn := range gossip.socket.GetConnections() {
+		if packet.Origin() != nil && conn.RemoteAddr().String() == packet.Origin().String() {
+			continue
+		}
+		if accepts(conn, packet.Instruction) {
+			targets = append(targets, conn)
+		}
+	}
+	rand.Shuffle(len(targets), func(i, j int) {
+		targets[i], targets[j] = targets[j], targets[i]
+	})
+	for i, conn := range targets {
+		switch {
+		case config.Fanout <= 0 || i < config.Fanout:
+			gossip.socket.Send(packet, conn.RemoteAddr())
+		case config.LazyPush && Supports(conn, CapabilityGossip):
+			gossip.socket.Send(Packet{Instruction: Inv, Data: []MessageID{id}}, conn.RemoteAddr())
+		}
+	}
+}
+
+// gossipCapabilities maps the instructions spread by gossip to the capability introducing them.
+var gossipCapabilities = map[Insn]string{
+	Transaction:     CapabilityTransactions,
+	ConnAnnouncment: CapabilityPeers,
+}
+
+// accepts reports whether packets with instruction may be spread to conn, which must have negotiated its capability,
+// unless it predates Hello, and so capabilities, in which case it handles every instruction gossiped.
+func accepts(conn net.Conn, instruction Insn) bool {
+	if _, ok := RemoteHello(conn); !ok {
+		return true
+	}
+	capability, ok := gossipCapabilities[instruction]
+	return !ok || Supports(conn, capability)
+}
+
+// remember stores packet for answering GetData, evicting the oldest. Requires gossip.lock to be held.
+func (gossip *Gossip) remember(id MessageID, packet Packet) {
+	if _, ok := gossip.store[id]; ok {
+		return
+	}
+	gossip.store[id] = packet.WithOrigin(nil)
+	gossip.stored = append(gossip.stored, id)
+	for len(gossip.stored) > storeCapacity {
+		delete(gossip.store, gossip.stored[0])
+		gossip.stored = gossip.stored[1:]
+	}
+}
+
+// expect retries the request for id with the next announcer unless answered within the
+// request timeout. Requires gossip.lock to be held.
+func (gossip *Gossip) expect(id MessageID, attempt int) {
+	time.AfterFunc(gossip.config.RequestTimeout, func() {
+		gossip.retry(id, attempt)
+	})
+}
+
+func (gossip *Gossip) retry(id MessageID, attempt int) {
+	gossip.lock.Lock()
+	wanted, ok := gossip.wanted[id]
+	if !ok || wanted.attempts != attempt {
+		gossip.lock.Unlock()
+		return // Received, or retried already.
+	}
+	if len(wanted.announcers) == 0 {
+		delete(gossip.wanted, id)
+		gossip.lock.Unlock()
+		log.Println("Giving up on requested message after attempts: ", attempt)
+		return
+	}
+	wanted.asked = wanted.announcers[0]
+	wanted.announcers = wanted.announcers[1:]
+	wanted.attempts++
+	asked := wanted.asked
+	gossip.expect(id, wanted.attempts)
+	gossip.lock.Unlock()
+	gossip.socket.Send(Packet{Instruction: GetData, Data: []MessageID{id}}, asked)
+}
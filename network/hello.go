@@ -0,0 +1,250 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ProtocolVersion is bumped on incompatible changes to the wire format. Nodes
+// refuse peers whose version is below their MinProtocolVersion.
+const (
+	ProtocolVersion    uint32 = 1
+	MinProtocolVersion uint32 = 1
+	DefaultChainID            = "vicoin"
+)
+
+// Capabilities announce optional groups of Insn a node understands. A node must only
+// send an Insn to peers which negotiated the capability introducing it, as older
+// nodes are unable to decode packets they don't know.
+const (
+	CapabilityPeers        = "peers/1"
+	CapabilityTransactions = "transactions/1"
+	CapabilityHeartbeat    = "heartbeat/1" // Ping, Pong
+	CapabilityGossip       = "gossip/1"    // Inv, GetData, Data
+	CapabilityDiscovery    = "discovery/1" // FindNode, Neighbours
+	CapabilityPex          = "pex/1"       // PexRequest, PexReply
+	CapabilityRPC          = "rpc/1"       // Request, Response
+)
+
+var DefaultCapabilities = []string{
+	CapabilityPeers,
+	CapabilityTransactions,
+	CapabilityHeartbeat,
+	CapabilityGossip,
+	CapabilityDiscovery,
+	CapabilityPex,
+	CapabilityRPC,
+}
+
+type Hello struct {
+	Version      uint32
+	MinVersion   uint32
+	NodeID       NodeID
+	ListenAddr   string
+	ChainID      string
+	Capabilities []string
+}
+
+type HelloAck struct {
+	Hello    Hello
+	Accepted bool
+	Reason   string
+}
+
+func NewHello(id NodeID, listenAddr net.Addr, chainID string) Hello {
+	return Hello{
+		Version:      ProtocolVersion,
+		MinVersion:   MinProtocolVersion,
+		NodeID:       id,
+		ListenAddr:   listenAddr.String(),
+		ChainID:      chainID,
+		Capabilities: DefaultCapabilities,
+	}
+}
+
+// HelloUpgrader exchanges Hellos with the remote, disconnecting incompatible nodes:
+//
+//	initiator -> Hello
+//	responder -> HelloAck (responders Hello, verdict on initiators Hello)
+//	initiator -> HelloAck (verdict on responders Hello)
+type HelloUpgrader struct {
+	hello   Hello
+	timeout time.Duration
+}
+
+func NewHelloUpgrader(hello Hello, timeout time.Duration) *HelloUpgrader {
+	return &HelloUpgrader{
+		hello:   hello,
+		timeout: timeout,
+	}
+}
+
+func (upgrader *HelloUpgrader) Upgrade(conn net.Conn, initiator bool) (net.Conn, error) {
+	if upgrader.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(upgrader.timeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+	var remote Hello
+	if initiator {
+		if err := writeMessage(conn, upgrader.hello); err != nil {
+			return nil, err
+		}
+		var ack HelloAck
+		if err := readMessage(conn, &ack); err != nil {
+			return nil, err
+		}
+		if !ack.Accepted {
+			return nil, fmt.Errorf("rejected by peer: %s", ack.Reason)
+		}
+		remote = ack.Hello
+		if err := upgrader.reply(conn, remote); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := readMessage(conn, &remote); err != nil {
+			return nil, err
+		}
+		if err := upgrader.reply(conn, remote); err != nil {
+			return nil, err
+		}
+		var ack HelloAck
+		if err := readMessage(conn, &ack); err != nil {
+			return nil, err
+		}
+		if !ack.Accepted {
+			return nil, fmt.Errorf("rejected by peer: %s", ack.Reason)
+		}
+	}
+	return newHelloConn(conn, upgrader.hello, remote), nil
+}
+
+// reply sends our verdict on the remote Hello, returning an error if it was rejected.
+func (upgrader *HelloUpgrader) reply(conn net.Conn, remote Hello) error {
+	verdict := checkHello(conn, upgrader.hello, remote)
+	ack := HelloAck{
+		Hello:    upgrader.hello,
+		Accepted: verdict == nil,
+		Reason:   "",
+	}
+	if verdict != nil {
+		ack.Reason = verdict.Error()
+	}
+	if err := writeMessage(conn, ack); err != nil {
+		return err
+	}
+	if verdict != nil {
+		return fmt.Errorf("rejected peer: %s", verdict.Error())
+	}
+	return nil
+}
+
+func checkHello(conn net.Conn, local Hello, remote Hello) error {
+	if remote.Version < local.MinVersion {
+		return fmt.Errorf("protocol version %d is below the minimum supported version %d", remote.Version, local.MinVersion)
+	}
+	if local.Version < remote.MinVersion {
+		return fmt.Errorf("protocol version %d is required, have %d", remote.MinVersion, local.Version)
+	}
+	if remote.ChainID != local.ChainID {
+		return fmt.Errorf("chain %q doesn't match %q", remote.ChainID, local.ChainID)
+	}
+	if remote.NodeID == local.NodeID {
+		return fmt.Errorf("connected to self")
+	}
+	if identity, ok := RemoteIdentity(conn); ok && IDFromIdentity(identity) != remote.NodeID {
+		return fmt.Errorf("node ID doesn't match the handshake identity")
+	}
+	return nil
+}
+
+// HelloConn is a net.Conn to a compatible node, remembering what was negotiated.
+type HelloConn struct {
+	net.Conn
+	remote       Hello
+	version      uint32
+	capabilities []string
+}
+
+func newHelloConn(conn net.Conn, local Hello, remote Hello) *HelloConn {
+	version := local.Version
+	if remote.Version < version {
+		version = remote.Version
+	}
+	capabilities := make([]string, 0)
+	for _, capability := range local.Capabilities {
+		if containsString(remote.Capabilities, capability) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	return &HelloConn{
+		Conn:         conn,
+		remote:       remote,
+		version:      version,
+		capabilities: capabilities,
+	}
+}
+
+func (conn *HelloConn) RemoteHello() Hello {
+	return conn.remote
+}
+
+// Version returns the protocol version spoken on the connection.
+func (conn *HelloConn) Version() uint32 {
+	return conn.version
+}
+
+// Capabilities returns the capabilities supported by both ends of the connection.
+func (conn *HelloConn) Capabilities() []string {
+	return conn.capabilities
+}
+
+func (conn *HelloConn) Unwrap() net.Conn {
+	return conn.Conn
+}
+
+// RemoteHello returns the Hello of the node at the other end of conn, if one was exchanged.
+func RemoteHello(conn net.Conn) (*HelloConn, bool) {
+	hello, ok := findConn(conn, func(conn net.Conn) bool {
+		_, ok := conn.(*HelloConn)
+		return ok
+	}).(*HelloConn)
+	return hello, ok
+}
+
+// Supports reports whether capability was negotiated on conn.
+func Supports(conn net.Conn, capability string) bool {
+	hello, ok := RemoteHello(conn)
+	if !ok {
+		return false
+	}
+	return containsString(hello.capabilities, capability)
+}
+
+func containsString(list []string, str string) bool {
+	for _, known := range list {
+		if known == str {
+			return true
+		}
+	}
+	return false
+}
+
+// Handshake messages are gob encoded into frames of their own, so no decoder reads past them.
+func writeMessage(conn net.Conn, message interface{}) error {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(message); err != nil {
+		return err
+	}
+	return writeFrame(conn, buffer.Bytes())
+}
+
+func readMessage(conn net.Conn, message interface{}) error {
+	frame, err := readFrame(conn)
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(frame)).Decode(message)
+}
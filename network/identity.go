@@ -0,0 +1,132 @@
+package network
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// NodeID identifies a node on the network, derived from its identity key.
+type NodeID [sha256.Size]byte
+
+func IDFromIdentity(identity ed25519.PublicKey) NodeID {
+	return sha256.Sum256(identity)
+}
+
+func (id NodeID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// NodeKey is the long-lived key of a node. The identity key is what peers know
+// the node by, the static key is the Curve25519 key used in the Noise handshake.
+type NodeKey struct {
+	identity ed25519.PrivateKey
+	static   *ecdh.PrivateKey
+}
+
+func NewNodeKey() (*NodeKey, error) {
+	_, identity, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	static, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &NodeKey{
+		identity: identity,
+		static:   static,
+	}, nil
+}
+
+// LoadNodeKey reads the key kept in the file at path, generating and writing it there if there is none yet,
+// so that the node keeps its NodeID across restarts. An empty path generates a new key.
+func LoadNodeKey(path string) (*NodeKey, error) {
+	if path == "" {
+		return NewNodeKey()
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := NewNodeKey()
+		if err != nil {
+			return nil, err
+		}
+		return key, os.WriteFile(path, key.marshal(), 0600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := unmarshalNodeKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node key file %q: %w", path, err)
+	}
+	return key, nil
+}
+
+func (key *NodeKey) Identity() ed25519.PublicKey {
+	return key.identity.Public().(ed25519.PublicKey)
+}
+
+func (key *NodeKey) ID() NodeID {
+	return IDFromIdentity(key.Identity())
+}
+
+// Internal
+
+// marshal encodes the seed of the identity key followed by the static key, in hex.
+func (key *NodeKey) marshal() []byte {
+	raw := append(append([]byte{}, key.identity.Seed()...), key.static.Bytes()...)
+	return []byte(hex.EncodeToString(raw))
+}
+
+func unmarshalNodeKey(data []byte) (*NodeKey, error) {
+	raw, err := hex.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != ed25519.SeedSize+32 {
+		return nil, fmt.Errorf("%d bytes, want %d", len(raw), ed25519.SeedSize+32)
+	}
+	static, err := ecdh.X25519().NewPrivateKey(raw[ed25519.SeedSize:])
+	if err != nil {
+		return nil, err
+	}
+	return &NodeKey{
+		identity: ed25519.NewKeyFromSeed(raw[:ed25519.SeedSize]),
+		static:   static,
+	}, nil
+}
+
+// RemoteIdentity returns the verified identity of the node at the other end of conn,
+// if conn (or a net.Conn it wraps) has been secured by a handshake.
+func RemoteIdentity(conn net.Conn) (ed25519.PublicKey, bool) {
+	secure, ok := findConn(conn, func(conn net.Conn) bool {
+		_, ok := conn.(*SecureConn)
+		return ok
+	}).(*SecureConn)
+	if !ok {
+		return nil, false
+	}
+	return secure.RemoteIdentity(), true
+}
+
+// findConn walks conn and the net.Conns it wraps, returning the first one matching, or nil.
+func findConn(conn net.Conn, match func(net.Conn) bool) net.Conn {
+	for conn != nil {
+		if match(conn) {
+			return conn
+		}
+		wrapper, ok := conn.(interface{ Unwrap() net.Conn })
+		if !ok {
+			return nil
+		}
+		conn = wrapper.Unwrap()
+	}
+	return nil
+}
@@ -10,6 +10,8 @@ type Socket interface {
 	Broadcast(data interface{}) []error
 	Send(data interface{}, addr net.Addr) error
 	GetAddr() net.Addr
+	GetConnections() map[string]net.Conn
+	Disconnect(addr net.Addr) error
 }
 
 type DialerStrategy interface {
@@ -20,3 +22,18 @@ type ListenerStrategy interface {
 	Accept() (net.Conn, error)
 	Addr() net.Addr
 }
+
+// DropNotifier is implemented by sockets able to tell when a net.Conn is lost.
+type DropNotifier interface {
+	OnDrop(handler func(addr net.Addr))
+}
+
+// AdmissionFilter is implemented by listeners able to refuse incoming net.Conns before upgrading them,
+// admit returning an error for those which would be refused anyway.
+type AdmissionFilter interface {
+	SetAdmission(admit func(addr net.Addr) error)
+}
+
+type UpgradeStrategy interface {
+	Upgrade(conn net.Conn, initiator bool) (net.Conn, error)
+}
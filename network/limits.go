@@ -0,0 +1,117 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"time"
+)
+
+var ErrConnectionLimit = errors.New("connection limit reached")
+
+// ConnectionLimits bounds the connections of a Polysocket, a zero field being unlimited.
+// Subnets are /24 for IPv4 and /64 for IPv6, the blocks a single party most easily holds.
+type ConnectionLimits struct {
+	MaxInbound   int
+	MaxOutbound  int
+	MaxPerIP     int
+	MaxPerSubnet int
+}
+
+var DefaultConnectionLimits = ConnectionLimits{
+	MaxInbound:   32,
+	MaxOutbound:  8,
+	MaxPerIP:     2,
+	MaxPerSubnet: 4,
+}
+
+// PeerStats describes an inbound connection considered for eviction.
+type PeerStats struct {
+	Addr       net.Addr
+	Connected  time.Time
+	Latency    time.Duration // Zero if unknown.
+	LastUseful time.Time     // Last time the peer sent something new, zero if never.
+}
+
+// EvictionStrategy picks which inbound connection to close to make room for a new one, if any.
+type EvictionStrategy interface {
+	Evict(candidates []PeerStats) (net.Addr, bool)
+}
+
+// ProtectingEviction protects the Protect peers with the lowest latency, the Protect peers most recently
+// useful and then the longest-lived half of the rest, so an attacker can't displace them by opening
+// connections. Of the remaining, the youngest connection of the most crowded subnet is evicted.
+type ProtectingEviction struct {
+	Protect int
+}
+
+func (eviction ProtectingEviction) Evict(candidates []PeerStats) (net.Addr, bool) {
+	remaining := append([]PeerStats{}, candidates...)
+	remaining = protect(remaining, eviction.Protect, func(first PeerStats, second PeerStats) bool {
+		if (first.Latency == 0) != (second.Latency == 0) {
+			return second.Latency == 0
+		}
+		return first.Latency < second.Latency
+	})
+	remaining = protect(remaining, eviction.Protect, func(first PeerStats, second PeerStats) bool {
+		return first.LastUseful.After(second.LastUseful)
+	})
+	remaining = protect(remaining, len(remaining)/2, func(first PeerStats, second PeerStats) bool {
+		return first.Connected.Before(second.Connected)
+	})
+	if len(remaining) == 0 {
+		return nil, false
+	}
+	crowds := make(map[string][]PeerStats)
+	crowdest := ""
+	for _, candidate := range remaining {
+		subnet := Subnet(candidate.Addr)
+		crowds[subnet] = append(crowds[subnet], candidate)
+		if crowdest == "" || len(crowds[subnet]) > len(crowds[crowdest]) {
+			crowdest = subnet
+		}
+	}
+	youngest := crowds[crowdest][0]
+	for _, candidate := range crowds[crowdest] {
+		if candidate.Connected.After(youngest.Connected) {
+			youngest = candidate
+		}
+	}
+	return youngest.Addr, true
+}
+
+// Subnet returns the /24 of an IPv4 address or the /64 of an IPv6 one.
+func Subnet(addr net.Addr) string {
+	ip := ipOf(addr)
+	if ip == nil {
+		return addr.String()
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// Internal
+
+// protect removes the count best candidates by less from them.
+func protect(candidates []PeerStats, count int, less func(first PeerStats, second PeerStats) bool) []PeerStats {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return less(candidates[i], candidates[j])
+	})
+	if count > len(candidates) {
+		count = len(candidates)
+	}
+	return candidates[count:]
+}
+
+func ipOf(addr net.Addr) net.IP {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
@@ -6,8 +6,9 @@ type TCPListener struct {
 	ln net.Listener
 }
 
-func NewTCPListener() (*TCPListener, error) {
-	listener, err := net.Listen("tcp", ":0")
+// NewTCPListener listens on addr, e.g. "0.0.0.0:4000". A port of 0 picks a free one.
+func NewTCPListener(addr string) (*TCPListener, error) {
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
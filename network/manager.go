@@ -0,0 +1,146 @@
+package network
+
+import (
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+type BackoffPolicy struct {
+	Initial     time.Duration
+	Max         time.Duration
+	Multiplier  float64
+	Jitter      float64 // Fraction of the delay by which it is randomly shortened or extended.
+	MaxAttempts int     // Zero retries forever.
+}
+
+var DefaultBackoffPolicy = BackoffPolicy{
+	Initial:     time.Second,
+	Max:         5 * time.Minute,
+	Multiplier:  2,
+	Jitter:      0.2,
+	MaxAttempts: 10,
+}
+
+// Delay returns how long to wait before the given attempt, counting from 1.
+func (policy BackoffPolicy) Delay(attempt int) time.Duration {
+	delay := float64(policy.Initial) * math.Pow(policy.Multiplier, float64(attempt-1))
+	if delay > float64(policy.Max) {
+		delay = float64(policy.Max)
+	}
+	delay *= 1 + policy.Jitter*(2*rand.Float64()-1)
+	return time.Duration(delay)
+}
+
+type ConnectionEventKind int
+
+const (
+	Reconnected ConnectionEventKind = 0
+	GaveUp      ConnectionEventKind = 1
+)
+
+type ConnectionEvent struct {
+	Kind     ConnectionEventKind
+	Addr     net.Addr
+	Attempts int
+	Err      error
+}
+
+// ConnectionManager is a Socket which redials peers it dialed once their connection drops.
+type ConnectionManager struct {
+	*Polysocket
+	policy BackoffPolicy
+	events chan ConnectionEvent
+	dialed map[string]net.Addr
+	closed bool
+	lock   sync.Mutex
+}
+
+func NewConnectionManager(polysocket *Polysocket, policy BackoffPolicy, events chan ConnectionEvent) *ConnectionManager {
+	manager := &ConnectionManager{
+		Polysocket: polysocket,
+		policy:     policy,
+		events:     events,
+		dialed:     make(map[string]net.Addr),
+		closed:     false,
+		lock:       sync.Mutex{},
+	}
+	polysocket.OnDrop(manager.dropped)
+	return manager
+}
+
+func (manager *ConnectionManager) Connect(addr net.Addr) (net.Conn, error) {
+	socket, err := manager.Polysocket.Connect(addr)
+	if err != nil {
+		return nil, err
+	}
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	manager.closed = false
+	manager.dialed[socket.RemoteAddr().String()] = addr
+	return socket, nil
+}
+
+func (manager *ConnectionManager) Close() []error {
+	manager.lock.Lock()
+	manager.closed = true
+	manager.dialed = make(map[string]net.Addr)
+	manager.lock.Unlock()
+	return manager.Polysocket.Close()
+}
+
+// Forget stops redialing addr, should its connection drop.
+func (manager *ConnectionManager) Forget(addr net.Addr) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	for key, dialed := range manager.dialed {
+		if key == addr.String() || dialed.String() == addr.String() {
+			delete(manager.dialed, key)
+		}
+	}
+}
+
+// Internal
+func (manager *ConnectionManager) dropped(remote net.Addr) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	addr, ok := manager.dialed[remote.String()]
+	if !ok || manager.closed {
+		return
+	}
+	go manager.redial(remote.String(), addr)
+}
+
+func (manager *ConnectionManager) redial(key string, addr net.Addr) {
+	var err error
+	for attempt := 1; manager.policy.MaxAttempts == 0 || attempt <= manager.policy.MaxAttempts; attempt++ {
+		time.Sleep(manager.policy.Delay(attempt))
+		if !manager.remembers(key) {
+			return
+		}
+		_, err = manager.Polysocket.Connect(addr)
+		if err == nil {
+			manager.emit(ConnectionEvent{Kind: Reconnected, Addr: addr, Attempts: attempt, Err: nil})
+			return
+		}
+	}
+	manager.lock.Lock()
+	delete(manager.dialed, key)
+	manager.lock.Unlock()
+	manager.emit(ConnectionEvent{Kind: GaveUp, Addr: addr, Attempts: manager.policy.MaxAttempts, Err: err})
+}
+
+func (manager *ConnectionManager) remembers(key string) bool {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	_, ok := manager.dialed[key]
+	return ok && !manager.closed
+}
+
+func (manager *ConnectionManager) emit(event ConnectionEvent) {
+	if manager.events != nil {
+		manager.events <- event
+	}
+}
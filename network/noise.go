@@ -0,0 +1,342 @@
+package network
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// Noise_XX_25519_AESGCM_SHA256, see https://noiseprotocol.org/noise.html
+//
+//	-> e
+//	<- e, ee, s, es
+//	-> s, se
+//
+// The payloads of the last two messages carry the identity key of the sender
+// together with a signature binding it to the static key of the handshake.
+const (
+	noiseProtocolName = "Noise_XX_25519_AESGCM_SHA256"
+	noisePrologue     = "vicoin"
+	noiseStaticPrefix = "vicoin-noise-static-key:"
+	noiseKeyLength    = 32
+	noiseTagLength    = 16
+	noisePayloadSize  = ed25519.PublicKeySize + ed25519.SignatureSize
+)
+
+var errNoiseMalformed = errors.New("malformed noise handshake message")
+
+type NoiseUpgrader struct {
+	key     *NodeKey
+	timeout time.Duration
+}
+
+func NewNoiseUpgrader(key *NodeKey, timeout time.Duration) *NoiseUpgrader {
+	return &NoiseUpgrader{
+		key:     key,
+		timeout: timeout,
+	}
+}
+
+// Upgrade performs the handshake on conn and returns a SecureConn to the verified remote node.
+func (upgrader *NoiseUpgrader) Upgrade(conn net.Conn, initiator bool) (net.Conn, error) {
+	if upgrader.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(upgrader.timeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+	handshake, err := newNoiseHandshake(upgrader.key)
+	if err != nil {
+		return nil, err
+	}
+	if initiator {
+		return handshake.initiate(conn)
+	}
+	return handshake.respond(conn)
+}
+
+type noiseHandshake struct {
+	key       *NodeKey
+	symmetric *symmetricState
+	ephemeral *ecdh.PrivateKey
+	remoteEph *ecdh.PublicKey
+	remoteSta *ecdh.PublicKey
+}
+
+func newNoiseHandshake(key *NodeKey) (*noiseHandshake, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	symmetric := newSymmetricState(noiseProtocolName)
+	symmetric.mixHash([]byte(noisePrologue))
+	return &noiseHandshake{
+		key:       key,
+		symmetric: symmetric,
+		ephemeral: ephemeral,
+	}, nil
+}
+
+func (handshake *noiseHandshake) initiate(conn net.Conn) (net.Conn, error) {
+	// -> e
+	message := handshake.ephemeral.PublicKey().Bytes()
+	handshake.symmetric.mixHash(message)
+	message = append(message, handshake.symmetric.encryptAndHash(nil)...)
+	if err := writeFrame(conn, message); err != nil {
+		return nil, err
+	}
+	// <- e, ee, s, es
+	message, err := readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) < noiseKeyLength {
+		return nil, errNoiseMalformed
+	}
+	if err = handshake.readEphemeral(message[:noiseKeyLength]); err != nil {
+		return nil, err
+	}
+	if err = handshake.mixDH(handshake.ephemeral, handshake.remoteEph); err != nil {
+		return nil, err
+	}
+	payload, err := handshake.readStatic(message[noiseKeyLength:])
+	if err != nil {
+		return nil, err
+	}
+	if err = handshake.mixDH(handshake.ephemeral, handshake.remoteSta); err != nil {
+		return nil, err
+	}
+	identity, err := handshake.readPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	// -> s, se
+	message = handshake.symmetric.encryptAndHash(handshake.key.static.PublicKey().Bytes())
+	if err = handshake.mixDH(handshake.key.static, handshake.remoteEph); err != nil {
+		return nil, err
+	}
+	message = append(message, handshake.symmetric.encryptAndHash(handshake.payload())...)
+	if err = writeFrame(conn, message); err != nil {
+		return nil, err
+	}
+	send, receive := handshake.symmetric.split()
+	return newSecureConn(conn, send, receive, identity), nil
+}
+
+func (handshake *noiseHandshake) respond(conn net.Conn) (net.Conn, error) {
+	// -> e
+	message, err := readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) < noiseKeyLength {
+		return nil, errNoiseMalformed
+	}
+	if err = handshake.readEphemeral(message[:noiseKeyLength]); err != nil {
+		return nil, err
+	}
+	if _, err = handshake.symmetric.decryptAndHash(message[noiseKeyLength:]); err != nil {
+		return nil, err
+	}
+	// <- e, ee, s, es
+	message = handshake.ephemeral.PublicKey().Bytes()
+	handshake.symmetric.mixHash(message)
+	if err = handshake.mixDH(handshake.ephemeral, handshake.remoteEph); err != nil {
+		return nil, err
+	}
+	message = append(message, handshake.symmetric.encryptAndHash(handshake.key.static.PublicKey().Bytes())...)
+	if err = handshake.mixDH(handshake.key.static, handshake.remoteEph); err != nil {
+		return nil, err
+	}
+	message = append(message, handshake.symmetric.encryptAndHash(handshake.payload())...)
+	if err = writeFrame(conn, message); err != nil {
+		return nil, err
+	}
+	// -> s, se
+	message, err = readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := handshake.readStatic(message)
+	if err != nil {
+		return nil, err
+	}
+	if err = handshake.mixDH(handshake.ephemeral, handshake.remoteSta); err != nil {
+		return nil, err
+	}
+	identity, err := handshake.readPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	receive, send := handshake.symmetric.split()
+	return newSecureConn(conn, send, receive, identity), nil
+}
+
+func (handshake *noiseHandshake) readEphemeral(data []byte) error {
+	remote, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return err
+	}
+	handshake.remoteEph = remote
+	handshake.symmetric.mixHash(data)
+	return nil
+}
+
+// readStatic decrypts the remote static key at the start of data and returns the remainder.
+func (handshake *noiseHandshake) readStatic(data []byte) ([]byte, error) {
+	if len(data) < noiseKeyLength+noiseTagLength {
+		return nil, errNoiseMalformed
+	}
+	static, err := handshake.symmetric.decryptAndHash(data[:noiseKeyLength+noiseTagLength])
+	if err != nil {
+		return nil, err
+	}
+	remote, err := ecdh.X25519().NewPublicKey(static)
+	if err != nil {
+		return nil, err
+	}
+	handshake.remoteSta = remote
+	return data[noiseKeyLength+noiseTagLength:], nil
+}
+
+// readPayload decrypts the identity payload and verifies that it signs the remote static key.
+func (handshake *noiseHandshake) readPayload(data []byte) (ed25519.PublicKey, error) {
+	payload, err := handshake.symmetric.decryptAndHash(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != noisePayloadSize {
+		return nil, errNoiseMalformed
+	}
+	identity := ed25519.PublicKey(payload[:ed25519.PublicKeySize])
+	signed := append([]byte(noiseStaticPrefix), handshake.remoteSta.Bytes()...)
+	if !ed25519.Verify(identity, signed, payload[ed25519.PublicKeySize:]) {
+		return nil, errors.New("remote identity doesn't sign the handshake key")
+	}
+	return identity, nil
+}
+
+func (handshake *noiseHandshake) payload() []byte {
+	signed := append([]byte(noiseStaticPrefix), handshake.key.static.PublicKey().Bytes()...)
+	signature := ed25519.Sign(handshake.key.identity, signed)
+	return append(append([]byte{}, handshake.key.Identity()...), signature...)
+}
+
+func (handshake *noiseHandshake) mixDH(local *ecdh.PrivateKey, remote *ecdh.PublicKey) error {
+	secret, err := local.ECDH(remote)
+	if err != nil {
+		return err
+	}
+	handshake.symmetric.mixKey(secret)
+	return nil
+}
+
+type symmetricState struct {
+	cipher *cipherState
+	ck     []byte
+	h      []byte
+}
+
+func newSymmetricState(protocolName string) *symmetricState {
+	h := make([]byte, sha256.Size)
+	if len(protocolName) <= sha256.Size {
+		copy(h, protocolName)
+	} else {
+		sum := sha256.Sum256([]byte(protocolName))
+		h = sum[:]
+	}
+	return &symmetricState{
+		cipher: &cipherState{},
+		ck:     append([]byte{}, h...),
+		h:      h,
+	}
+}
+
+func (state *symmetricState) mixHash(data []byte) {
+	hash := sha256.New()
+	hash.Write(state.h)
+	hash.Write(data)
+	state.h = hash.Sum(nil)
+}
+
+func (state *symmetricState) mixKey(input []byte) {
+	var key []byte
+	state.ck, key = hkdf(state.ck, input)
+	state.cipher = newCipherState(key)
+}
+
+func (state *symmetricState) encryptAndHash(plaintext []byte) []byte {
+	ciphertext := state.cipher.encrypt(state.h, plaintext)
+	state.mixHash(ciphertext)
+	return ciphertext
+}
+
+func (state *symmetricState) decryptAndHash(ciphertext []byte) ([]byte, error) {
+	plaintext, err := state.cipher.decrypt(state.h, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	state.mixHash(ciphertext)
+	return plaintext, nil
+}
+
+// split returns the cipher for messages from the initiator, followed by the one for messages from the responder.
+func (state *symmetricState) split() (*cipherState, *cipherState) {
+	first, second := hkdf(state.ck, nil)
+	return newCipherState(first), newCipherState(second)
+}
+
+type cipherState struct {
+	aead  cipher.AEAD
+	nonce uint64
+}
+
+func newCipherState(key []byte) *cipherState {
+	block, _ := aes.NewCipher(key) // Key is always 32 bytes.
+	aead, _ := cipher.NewGCM(block)
+	return &cipherState{
+		aead:  aead,
+		nonce: 0,
+	}
+}
+
+func (state *cipherState) encrypt(ad []byte, plaintext []byte) []byte {
+	if state.aead == nil {
+		return append([]byte{}, plaintext...)
+	}
+	ciphertext := state.aead.Seal(nil, state.nextNonce(), plaintext, ad)
+	return ciphertext
+}
+
+func (state *cipherState) decrypt(ad []byte, ciphertext []byte) ([]byte, error) {
+	if state.aead == nil {
+		return append([]byte{}, ciphertext...), nil
+	}
+	return state.aead.Open(nil, state.nextNonce(), ciphertext, ad)
+}
+
+func (state *cipherState) nextNonce() []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], state.nonce)
+	state.nonce++
+	return nonce
+}
+
+func hkdf(chainingKey []byte, input []byte) ([]byte, []byte) {
+	temp := hmacSum(chainingKey, input)
+	first := hmacSum(temp, []byte{0x01})
+	second := hmacSum(temp, append(append([]byte{}, first...), 0x02))
+	return first, second
+}
+
+func hmacSum(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
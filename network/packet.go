@@ -1,5 +1,9 @@
 package network
 
+import "net"
+
+// Insn identifies the content of a Packet. Instructions are only ever added, each
+// new group of them announced as a capability in the Hello, see DefaultCapabilities.
 type Insn int8
 
 const (
@@ -7,9 +11,33 @@ const (
 	PeerReply       Insn = 1
 	ConnAnnouncment Insn = 2
 	Transaction     Insn = 3
+	Ping            Insn = 4
+	Pong            Insn = 5
+	Inv             Insn = 6
+	GetData         Insn = 7
+	Data            Insn = 8
+	FindNode        Insn = 9
+	Neighbours      Insn = 10
+	PexRequest      Insn = 11
+	PexReply        Insn = 12
+	Request         Insn = 13
+	Response        Insn = 14
 )
 
 type Packet struct {
 	Instruction Insn
 	Data        interface{}
+	TTL         uint8    // Remaining hops of a gossiped packet.
+	origin      net.Addr // Set upon receipt, never sent.
+}
+
+// WithOrigin returns a copy of the packet marked as received from origin.
+func (packet Packet) WithOrigin(origin net.Addr) Packet {
+	packet.origin = origin
+	return packet
+}
+
+// Origin returns the remote address of the net.Conn the packet was received on, or nil if it wasn't received.
+func (packet Packet) Origin() net.Addr {
+	return packet.origin
 }
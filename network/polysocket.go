@@ -7,14 +7,49 @@ import (
 	"log"
 	"net"
 	"sync"
+	"time"
 )
 
+// MaxMessageSize bounds the encoded size of a single message, the net.Conn of a remote
+// exceeding it is dropped. Reads are buffered, so a message may exceed it by a buffer.
+const MaxMessageSize = 1 << 20
+
+// messageReader counts the bytes read since the last reset, failing once they exceed MaxMessageSize.
+type messageReader struct {
+	reader io.Reader
+	read   int
+}
+
+func (reader *messageReader) Read(buffer []byte) (int, error) {
+	remaining := MaxMessageSize + 4096 - reader.read
+	if remaining <= 0 {
+		return 0, fmt.Errorf("message exceeds %d bytes", MaxMessageSize)
+	}
+	if len(buffer) > remaining {
+		buffer = buffer[:remaining]
+	}
+	n, err := reader.reader.Read(buffer)
+	reader.read += n
+	return n, err
+}
+
+type connMeta struct {
+	inbound   bool
+	connected time.Time
+}
+
 type Polysocket struct {
 	listener    ListenerStrategy
 	dialer      DialerStrategy
 	connections map[string]net.Conn
+	encoders    map[string]*gob.Encoder
+	meta        map[string]connMeta
+	limits      ConnectionLimits
+	eviction    EvictionStrategy
+	dialing     int // Outbound connections being dialed, counted against the limit.
 	addr        net.Addr
 	channel     chan interface{}
+	onDrop      []func(addr net.Addr)
 	lock        sync.Mutex
 }
 
@@ -23,24 +58,45 @@ func NewPolysocket(internal chan interface{}, dialerStrategy DialerStrategy, lis
 		listener:    listenerStrategy,
 		dialer:      dialerStrategy,
 		connections: make(map[string]net.Conn),
+		encoders:    make(map[string]*gob.Encoder),
+		meta:        make(map[string]connMeta),
+		limits:      ConnectionLimits{},
+		eviction:    ProtectingEviction{Protect: 4},
+		dialing:     0,
 		addr:        nil,
 		channel:     internal,
+		onDrop:      make([]func(addr net.Addr), 0),
 		lock:        sync.Mutex{},
 	}
 	polysocket.addr = polysocket.listener.Addr()
+	if filter, ok := listenerStrategy.(AdmissionFilter); ok {
+		filter.SetAdmission(polysocket.admissible)
+	}
 	go polysocket.listen()
 	return polysocket
 }
 
 func (polysocket *Polysocket) Connect(addr net.Addr) (net.Conn, error) {
-	socket, err := polysocket.dialer.Dial(addr)
+	polysocket.lock.Lock()
+	err := polysocket.check(addr)
+	if err == nil && polysocket.limits.MaxOutbound > 0 && polysocket.count(false)+polysocket.dialing >= polysocket.limits.MaxOutbound {
+		err = fmt.Errorf("%w: %d outbound", ErrConnectionLimit, polysocket.limits.MaxOutbound)
+	}
+	if err == nil {
+		polysocket.dialing++ // Reserves the slot while dialing.
+	}
+	polysocket.lock.Unlock()
 	if err != nil {
 		return nil, err
 	}
+	socket, err := polysocket.dialer.Dial(addr)
 	polysocket.lock.Lock()
-	go polysocket.handle(socket)
-	polysocket.connections[socket.RemoteAddr().String()] = socket
-	polysocket.lock.Unlock()
+	defer polysocket.lock.Unlock()
+	polysocket.dialing--
+	if err != nil {
+		return nil, err
+	}
+	polysocket.add(socket, false)
 	return socket, nil
 }
 
@@ -55,6 +111,8 @@ func (polysocket *Polysocket) Close() []error {
 		}
 	}
 	polysocket.connections = make(map[string]net.Conn)
+	polysocket.encoders = make(map[string]*gob.Encoder)
+	polysocket.meta = make(map[string]connMeta)
 	return errors
 }
 
@@ -62,24 +120,22 @@ func (polysocket *Polysocket) Broadcast(data interface{}) []error {
 	polysocket.lock.Lock()
 	defer polysocket.lock.Unlock()
 	var errors []error
-	for _, socket := range polysocket.connections {
-		fmt.Println("Writing")
-		enc := gob.NewEncoder(socket)
+	for _, enc := range polysocket.encoders {
 		err := enc.Encode(&data)
 		if err != nil {
 			errors = append(errors, err)
 		}
 	}
-	fmt.Println("Done")
 	return errors
 }
 
 func (polysocket *Polysocket) Send(data interface{}, addr net.Addr) error {
 	polysocket.lock.Lock()
 	defer polysocket.lock.Unlock()
-	socket := polysocket.connections[addr.String()]
-	fmt.Println(socket.RemoteAddr())
-	enc := gob.NewEncoder(socket)
+	enc, ok := polysocket.encoders[addr.String()]
+	if !ok {
+		return fmt.Errorf("no connection to %s", addr.String())
+	}
 	err := enc.Encode(&data)
 	if err != nil {
 		return err
@@ -90,45 +146,204 @@ func (polysocket *Polysocket) Send(data interface{}, addr net.Addr) error {
 func (polysocket *Polysocket) GetConnections() map[string]net.Conn {
 	polysocket.lock.Lock()
 	defer polysocket.lock.Unlock()
-	return polysocket.connections
+	connections := make(map[string]net.Conn, len(polysocket.connections))
+	for key, socket := range polysocket.connections {
+		connections[key] = socket
+	}
+	return connections
+}
+
+// Disconnect closes the net.Conn to addr, without notifying OnDrop handlers.
+func (polysocket *Polysocket) Disconnect(addr net.Addr) error {
+	polysocket.lock.Lock()
+	defer polysocket.lock.Unlock()
+	socket, ok := polysocket.connections[addr.String()]
+	if !ok {
+		return fmt.Errorf("no connection to %s", addr.String())
+	}
+	delete(polysocket.connections, addr.String())
+	delete(polysocket.encoders, addr.String())
+	delete(polysocket.meta, addr.String())
+	return socket.Close()
+}
+
+// SetLimits bounds the connections from now on, existing ones are kept.
+func (polysocket *Polysocket) SetLimits(limits ConnectionLimits) {
+	polysocket.lock.Lock()
+	defer polysocket.lock.Unlock()
+	polysocket.limits = limits
+}
+
+// SetEvictionStrategy sets how an inbound connection is picked for eviction once they are at their maximum.
+func (polysocket *Polysocket) SetEvictionStrategy(eviction EvictionStrategy) {
+	polysocket.lock.Lock()
+	defer polysocket.lock.Unlock()
+	polysocket.eviction = eviction
 }
 
 func (polysocket *Polysocket) GetAddr() net.Addr {
 	return polysocket.addr
 }
 
+// OnDrop registers a handler called with the remote address of every net.Conn lost
+// to a remote disconnect or error, but not of those closed by Close.
+func (polysocket *Polysocket) OnDrop(handler func(addr net.Addr)) {
+	polysocket.lock.Lock()
+	defer polysocket.lock.Unlock()
+	polysocket.onDrop = append(polysocket.onDrop, handler)
+}
+
 // Internal
 func (polysocket *Polysocket) listen() {
 	for {
 		socket, err := polysocket.listener.Accept()
 		if err != nil {
 			log.Println("Incoming net.Conn dropped: ", err)
+			continue
 		}
-		log.Println("Incoming net.Conn accepted: ", socket.RemoteAddr().String())
-		polysocket.lock.Lock()
-		go polysocket.handle(socket)
-		polysocket.connections[socket.RemoteAddr().String()] = socket
-		polysocket.lock.Unlock()
+		polysocket.admit(socket)
 	}
 }
 
+// admissible returns an error if a net.Conn from addr would be refused once accepted, so the
+// listener refuses it before spending an upgrade on it.
+func (polysocket *Polysocket) admissible(addr net.Addr) error {
+	polysocket.lock.Lock()
+	err := polysocket.check(addr)
+	full := polysocket.limits.MaxInbound > 0 && polysocket.count(true) >= polysocket.limits.MaxInbound
+	candidates := polysocket.inbound()
+	eviction := polysocket.eviction
+	polysocket.lock.Unlock()
+	if err == nil && full {
+		if _, ok := eviction.Evict(candidates); !ok {
+			err = fmt.Errorf("%w: %d inbound", ErrConnectionLimit, polysocket.limits.MaxInbound)
+		}
+	}
+	return err
+}
+
+// admit adds an accepted net.Conn within the limits, evicting an inbound one if they are at their maximum.
+func (polysocket *Polysocket) admit(socket net.Conn) {
+	addr := socket.RemoteAddr()
+	polysocket.lock.Lock()
+	err := polysocket.check(addr)
+	full := polysocket.limits.MaxInbound > 0 && polysocket.count(true) >= polysocket.limits.MaxInbound
+	candidates := polysocket.inbound()
+	eviction := polysocket.eviction
+	polysocket.lock.Unlock()
+	if err == nil && full {
+		evicted, ok := eviction.Evict(candidates)
+		if ok {
+			log.Println("Evicting inbound net.Conn: ", evicted)
+			polysocket.Disconnect(evicted)
+		} else {
+			err = fmt.Errorf("%w: %d inbound", ErrConnectionLimit, polysocket.limits.MaxInbound)
+		}
+	}
+	if err != nil {
+		log.Println("Refusing incoming net.Conn: ", err)
+		socket.Close()
+		return
+	}
+	log.Println("Incoming net.Conn accepted: ", addr.String())
+	polysocket.lock.Lock()
+	defer polysocket.lock.Unlock()
+	polysocket.add(socket, true)
+}
+
+// inbound returns the stats of the inbound connections, candidates for eviction. Requires polysocket.lock to be held.
+func (polysocket *Polysocket) inbound() []PeerStats {
+	candidates := make([]PeerStats, 0)
+	for key, meta := range polysocket.meta {
+		if meta.inbound {
+			candidates = append(candidates, PeerStats{Addr: polysocket.connections[key].RemoteAddr(), Connected: meta.connected})
+		}
+	}
+	return candidates
+}
+
+// check returns an error if a connection to addr would exceed the limits per IP or subnet,
+// which don't apply to loopback addresses. Requires polysocket.lock to be held.
+func (polysocket *Polysocket) check(addr net.Addr) error {
+	if ip := ipOf(addr); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	sameIP, sameSubnet := 0, 0
+	for _, socket := range polysocket.connections {
+		if ip := ipOf(socket.RemoteAddr()); ip != nil && ip.Equal(ipOf(addr)) {
+			sameIP++
+		}
+		if Subnet(socket.RemoteAddr()) == Subnet(addr) {
+			sameSubnet++
+		}
+	}
+	if polysocket.limits.MaxPerIP > 0 && sameIP >= polysocket.limits.MaxPerIP {
+		return fmt.Errorf("%w: %d per IP", ErrConnectionLimit, polysocket.limits.MaxPerIP)
+	}
+	if polysocket.limits.MaxPerSubnet > 0 && sameSubnet >= polysocket.limits.MaxPerSubnet {
+		return fmt.Errorf("%w: %d per subnet", ErrConnectionLimit, polysocket.limits.MaxPerSubnet)
+	}
+	return nil
+}
+
+// count returns the number of inbound or outbound connections. Requires polysocket.lock to be held.
+func (polysocket *Polysocket) count(inbound bool) int {
+	count := 0
+	for _, meta := range polysocket.meta {
+		if meta.inbound == inbound {
+			count++
+		}
+	}
+	return count
+}
+
+// Each net.Conn keeps a single gob.Encoder, as the remote decoder only accepts a type definition once per stream.
+// Requires polysocket.lock to be held.
+func (polysocket *Polysocket) add(socket net.Conn, inbound bool) {
+	go polysocket.handle(socket)
+	polysocket.connections[socket.RemoteAddr().String()] = socket
+	polysocket.encoders[socket.RemoteAddr().String()] = gob.NewEncoder(socket)
+	polysocket.meta[socket.RemoteAddr().String()] = connMeta{inbound: inbound, connected: time.Now()}
+}
+
 func (polysocket *Polysocket) handle(socket net.Conn) {
 	defer socket.Close()
 	var buffer interface{}
-	dec := gob.NewDecoder(socket)
+	reader := &messageReader{reader: socket, read: 0}
+	dec := gob.NewDecoder(reader)
 	for {
+		reader.read = 0
 		err := dec.Decode(&buffer)
 		if err == io.EOF {
 			log.Println("net.Conn closed by " + socket.RemoteAddr().String())
-			polysocket.lock.Lock()
-			delete(polysocket.connections, socket.RemoteAddr().String())
-			polysocket.lock.Unlock()
+			polysocket.drop(socket)
 			return
 		}
 		if err != nil {
 			log.Println("Error when decoding: ", err.Error())
-			break
+			polysocket.drop(socket)
+			return
+		}
+		if packet, ok := buffer.(Packet); ok {
+			buffer = packet.WithOrigin(socket.RemoteAddr())
 		}
 		polysocket.channel <- buffer
 	}
 }
+
+func (polysocket *Polysocket) drop(socket net.Conn) {
+	polysocket.lock.Lock()
+	key := socket.RemoteAddr().String()
+	if current, ok := polysocket.connections[key]; !ok || current != socket {
+		polysocket.lock.Unlock()
+		return // Closed locally, or already replaced by a new net.Conn.
+	}
+	delete(polysocket.connections, key)
+	delete(polysocket.encoders, key)
+	delete(polysocket.meta, key)
+	handlers := polysocket.onDrop
+	polysocket.lock.Unlock()
+	for _, handler := range handlers {
+		handler(socket.RemoteAddr())
+	}
+}
@@ -0,0 +1,118 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Rate is a sustained rate of packets per second, along with the burst allowed above it.
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// TokenBucket allows an event per token, refilling at the rate up to the burst.
+type TokenBucket struct {
+	rate   Rate
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(rate Rate) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		tokens: float64(rate.Burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes a token if there is one, reporting whether it did.
+func (bucket *TokenBucket) Allow() bool {
+	now := time.Now()
+	bucket.tokens += now.Sub(bucket.last).Seconds() * bucket.rate.PerSecond
+	if bucket.tokens > float64(bucket.rate.Burst) {
+		bucket.tokens = float64(bucket.rate.Burst)
+	}
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// Buckets of peers unheard of for idleBuckets are forgotten, once more than pruneBuckets are kept.
+const (
+	idleBuckets  = 10 * time.Minute
+	pruneBuckets = 1000
+)
+
+type peerBuckets struct {
+	total        *TokenBucket
+	instructions map[Insn]*TokenBucket
+}
+
+// RateLimiter limits the packets received from each peer, in total and per Insn.
+// Instructions without a rate are only limited by the total.
+type RateLimiter struct {
+	total        Rate
+	instructions map[Insn]Rate
+	peers        map[string]*peerBuckets
+	lock         sync.Mutex
+}
+
+func NewRateLimiter(total Rate, instructions map[Insn]Rate) *RateLimiter {
+	return &RateLimiter{
+		total:        total,
+		instructions: instructions,
+		peers:        make(map[string]*peerBuckets),
+		lock:         sync.Mutex{},
+	}
+}
+
+// Allow reports whether a packet with the instruction from addr is within the limits, counting it if so.
+func (limiter *RateLimiter) Allow(addr net.Addr, instruction Insn) bool {
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+	buckets, ok := limiter.peers[addr.String()]
+	if !ok {
+		if len(limiter.peers) >= pruneBuckets {
+			limiter.prune()
+		}
+		buckets = &peerBuckets{
+			total:        NewTokenBucket(limiter.total),
+			instructions: make(map[Insn]*TokenBucket),
+		}
+		limiter.peers[addr.String()] = buckets
+	}
+	if rate, ok := limiter.instructions[instruction]; ok {
+		bucket, ok := buckets.instructions[instruction]
+		if !ok {
+			bucket = NewTokenBucket(rate)
+			buckets.instructions[instruction] = bucket
+		}
+		if !bucket.Allow() {
+			return false
+		}
+	}
+	return buckets.total.Allow()
+}
+
+// Forget drops the buckets of addr.
+func (limiter *RateLimiter) Forget(addr net.Addr) {
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+	delete(limiter.peers, addr.String())
+}
+
+// Internal
+
+// prune forgets idle peers. Requires limiter.lock to be held.
+func (limiter *RateLimiter) prune() {
+	for key, buckets := range limiter.peers {
+		if time.Since(buckets.total.last) > idleBuckets {
+			delete(limiter.peers, key)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var ErrUnknownInstruction = errors.New("unknown instruction")
+
+// DecodeError describes why the payload of a Packet was refused.
+type DecodeError struct {
+	Instruction Insn
+	Err         error
+}
+
+func (err *DecodeError) Error() string {
+	return fmt.Sprintf("invalid packet (instruction %d): %v", err.Instruction, err.Err)
+}
+
+func (err *DecodeError) Unwrap() error {
+	return err.Err
+}
+
+type messageType struct {
+	payload  reflect.Type
+	validate func(data interface{}) error
+}
+
+// Registry maps each Insn to the concrete type of its payload and a validator for it,
+// so the payload of a Packet is only used once it has been checked.
+type Registry struct {
+	messages map[Insn]messageType
+	lock     sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		messages: make(map[Insn]messageType),
+		lock:     sync.RWMutex{},
+	}
+}
+
+// Register declares example as the payload type of insn. validate may be nil if any value of the type is valid.
+func (registry *Registry) Register(insn Insn, example interface{}, validate func(data interface{}) error) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	registry.messages[insn] = messageType{
+		payload:  reflect.TypeOf(example),
+		validate: validate,
+	}
+}
+
+// Decode returns the payload of packet if it has the registered type and passes validation, or a *DecodeError.
+func (registry *Registry) Decode(packet Packet) (interface{}, error) {
+	registry.lock.RLock()
+	message, ok := registry.messages[packet.Instruction]
+	registry.lock.RUnlock()
+	if !ok {
+		return nil, &DecodeError{packet.Instruction, ErrUnknownInstruction}
+	}
+	if packet.Data == nil || reflect.TypeOf(packet.Data) != message.payload {
+		return nil, &DecodeError{packet.Instruction, fmt.Errorf("payload of type %T, want %s", packet.Data, message.payload)}
+	}
+	if message.validate != nil {
+		if err := message.validate(packet.Data); err != nil {
+			return nil, &DecodeError{packet.Instruction, err}
+		}
+	}
+	return packet.Data, nil
+}
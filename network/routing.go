@@ -0,0 +1,139 @@
+package network
+
+import (
+	"bytes"
+	"math/bits"
+	"net"
+	"sort"
+	"sync"
+)
+
+// BucketSize is the number of contacts kept per bucket, and returned by a lookup.
+const BucketSize = 20
+
+// Contact is a node on the network along with the address it listens on.
+type Contact struct {
+	ID   NodeID
+	Addr net.Addr
+}
+
+// Distance returns the XOR distance between two node IDs, compared as big-endian integers.
+func Distance(first NodeID, second NodeID) NodeID {
+	var distance NodeID
+	for i := range distance {
+		distance[i] = first[i] ^ second[i]
+	}
+	return distance
+}
+
+// Closer reports whether first is closer to target than second.
+func Closer(target NodeID, first NodeID, second NodeID) bool {
+	firstDistance := Distance(target, first)
+	secondDistance := Distance(target, second)
+	return bytes.Compare(firstDistance[:], secondDistance[:]) < 0
+}
+
+// SortByDistance orders contacts from closest to furthest from target.
+func SortByDistance(contacts []Contact, target NodeID) {
+	sort.Slice(contacts, func(i, j int) bool {
+		return Closer(target, contacts[i].ID, contacts[j].ID)
+	})
+}
+
+// RoutingTable keeps contacts in buckets by the length of the prefix their ID shares
+// with the own ID, so it knows many nodes close to itself and a few far away.
+type RoutingTable struct {
+	self    NodeID
+	buckets [len(NodeID{}) * 8][]Contact // Least recently seen first.
+	lock    sync.Mutex
+}
+
+func NewRoutingTable(self NodeID) *RoutingTable {
+	return &RoutingTable{
+		self: self,
+		lock: sync.Mutex{},
+	}
+}
+
+func (table *RoutingTable) Self() NodeID {
+	return table.self
+}
+
+// Add records contact as seen. Known contacts are moved to the back of their bucket,
+// new ones are only added while the bucket has room, long-lived contacts being the most
+// likely to stay. It reports whether the contact is in the table.
+func (table *RoutingTable) Add(contact Contact) bool {
+	if contact.ID == table.self || contact.Addr == nil {
+		return false
+	}
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	index := table.bucketOf(contact.ID)
+	bucket := table.buckets[index]
+	for i, known := range bucket {
+		if known.ID == contact.ID {
+			bucket = append(bucket[:i], bucket[i+1:]...)
+			table.buckets[index] = append(bucket, contact)
+			return true
+		}
+	}
+	if len(bucket) >= BucketSize {
+		return false
+	}
+	table.buckets[index] = append(bucket, contact)
+	return true
+}
+
+func (table *RoutingTable) Remove(id NodeID) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	index := table.bucketOf(id)
+	bucket := table.buckets[index]
+	for i, known := range bucket {
+		if known.ID == id {
+			table.buckets[index] = append(bucket[:i], bucket[i+1:]...)
+			return
+		}
+	}
+}
+
+// Closest returns up to count contacts closest to target, closest first.
+func (table *RoutingTable) Closest(target NodeID, count int) []Contact {
+	contacts := table.Contacts()
+	SortByDistance(contacts, target)
+	if len(contacts) > count {
+		contacts = contacts[:count]
+	}
+	return contacts
+}
+
+func (table *RoutingTable) Contacts() []Contact {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	contacts := make([]Contact, 0)
+	for _, bucket := range table.buckets {
+		contacts = append(contacts, bucket...)
+	}
+	return contacts
+}
+
+func (table *RoutingTable) Len() int {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	count := 0
+	for _, bucket := range table.buckets {
+		count += len(bucket)
+	}
+	return count
+}
+
+// Internal
+func (table *RoutingTable) bucketOf(id NodeID) int {
+	distance := Distance(table.self, id)
+	for i, b := range distance {
+		if b != 0 {
+			return i*8 + bits.LeadingZeros8(b)
+		}
+	}
+	return len(table.buckets) - 1
+}
@@ -0,0 +1,183 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultRPCTimeout bounds calls made with a context without a deadline.
+const (
+	DefaultRPCTimeout = 10 * time.Second
+	MaxMethodLength   = 64
+)
+
+var ErrUnknownMethod = errors.New("unknown method")
+
+// RPCRequest is the payload of a Request packet, answered by a Response with the same ID.
+type RPCRequest struct {
+	ID     uint64
+	Method string
+	Body   interface{}
+}
+
+// RPCResponse is the payload of a Response packet. Error is set instead of Body if the request failed.
+type RPCResponse struct {
+	ID    uint64
+	Body  interface{}
+	Error string
+}
+
+// RemoteError is an error returned by the handler of the called peer.
+type RemoteError struct {
+	Method  string
+	Message string
+}
+
+func (err *RemoteError) Error() string {
+	return fmt.Sprintf("%s failed remotely: %s", err.Method, err.Message)
+}
+
+// RPCHandler answers a request from the peer at from.
+type RPCHandler func(ctx context.Context, from net.Addr, body interface{}) (interface{}, error)
+
+type pendingCall struct {
+	addr    net.Addr
+	replies chan RPCResponse
+}
+
+// RPC layers request and response over the packets of a Socket, matching them by ID.
+type RPC struct {
+	socket   Socket
+	handlers map[string]RPCHandler
+	pending  map[uint64]pendingCall
+	nextID   uint64
+	lock     sync.Mutex
+}
+
+func NewRPC(socket Socket) *RPC {
+	return &RPC{
+		socket:   socket,
+		handlers: make(map[string]RPCHandler),
+		pending:  make(map[uint64]pendingCall),
+		nextID:   0,
+		lock:     sync.Mutex{},
+	}
+}
+
+// Handle sets the handler of requests for method.
+func (rpc *RPC) Handle(method string, handler RPCHandler) {
+	rpc.lock.Lock()
+	defer rpc.lock.Unlock()
+	rpc.handlers[method] = handler
+}
+
+// HandleTyped sets a handler of requests for method whose bodies are of type Request,
+// refusing others without calling it.
+func HandleTyped[Request any, Response any](rpc *RPC, method string, handler func(ctx context.Context, from net.Addr, request Request) (Response, error)) {
+	rpc.Handle(method, func(ctx context.Context, from net.Addr, body interface{}) (interface{}, error) {
+		request, ok := body.(Request)
+		if !ok {
+			var want Request
+			return nil, fmt.Errorf("body of type %T, want %T", body, want)
+		}
+		return handler(ctx, from, request)
+	})
+}
+
+// Call sends a request for method to the peer at addr, waiting for its response until ctx is done,
+// or for DefaultRPCTimeout if ctx has no deadline.
+func (rpc *RPC) Call(ctx context.Context, addr net.Addr, method string, body interface{}) (interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRPCTimeout)
+		defer cancel()
+	}
+	replies := make(chan RPCResponse, 1)
+	rpc.lock.Lock()
+	rpc.nextID++
+	id := rpc.nextID
+	rpc.pending[id] = pendingCall{addr: addr, replies: replies}
+	rpc.lock.Unlock()
+	defer func() {
+		rpc.lock.Lock()
+		delete(rpc.pending, id)
+		rpc.lock.Unlock()
+	}()
+	request := Packet{
+		Instruction: Request,
+		Data:        RPCRequest{ID: id, Method: method, Body: body},
+	}
+	if err := rpc.socket.Send(request, addr); err != nil {
+		return nil, err
+	}
+	select {
+	case response := <-replies:
+		if response.Error != "" {
+			return nil, &RemoteError{Method: method, Message: response.Error}
+		}
+		return response.Body, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s to %s: %w", method, addr, ctx.Err())
+	}
+}
+
+// CallTyped calls method like Call, returning the body of the response as a Response.
+func CallTyped[Response any](ctx context.Context, rpc *RPC, addr net.Addr, method string, body interface{}) (Response, error) {
+	var response Response
+	result, err := rpc.Call(ctx, addr, method, body)
+	if err != nil {
+		return response, err
+	}
+	response, ok := result.(Response)
+	if !ok {
+		return response, fmt.Errorf("%s answered with %T, want %T", method, result, response)
+	}
+	return response, nil
+}
+
+// HandleRequest answers a received request in the background, so slow handlers don't hold up other packets.
+func (rpc *RPC) HandleRequest(packet Packet, request RPCRequest) {
+	if packet.Origin() == nil {
+		return
+	}
+	rpc.lock.Lock()
+	handler, ok := rpc.handlers[request.Method]
+	rpc.lock.Unlock()
+	go func() {
+		response := RPCResponse{ID: request.ID}
+		if !ok {
+			response.Error = fmt.Sprintf("%s: %v", request.Method, ErrUnknownMethod)
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), DefaultRPCTimeout)
+			defer cancel()
+			body, err := handler(ctx, packet.Origin(), request.Body)
+			if err != nil {
+				response.Error = err.Error()
+			} else {
+				response.Body = body
+			}
+		}
+		rpc.socket.Send(Packet{Instruction: Response, Data: response}, packet.Origin())
+	}()
+}
+
+// HandleResponse passes a received response to its call, reporting whether one was waiting for it from that peer.
+func (rpc *RPC) HandleResponse(packet Packet, response RPCResponse) bool {
+	rpc.lock.Lock()
+	call, ok := rpc.pending[response.ID]
+	if ok && (packet.Origin() == nil || call.addr.String() != packet.Origin().String()) {
+		ok = false // Answered by another peer than the one asked.
+	}
+	if ok {
+		delete(rpc.pending, response.ID)
+	}
+	rpc.lock.Unlock()
+	if ok {
+		call.replies <- response
+	}
+	return ok
+}
@@ -0,0 +1,77 @@
+package network
+
+import (
+	"crypto/ed25519"
+	"net"
+	"sync"
+)
+
+const maxSecurePlaintext = maxFrameSize - noiseTagLength
+
+// SecureConn is a net.Conn encrypted and authenticated by a completed Noise handshake.
+type SecureConn struct {
+	net.Conn
+	send      *cipherState
+	receive   *cipherState
+	identity  ed25519.PublicKey
+	buffer    []byte
+	readLock  sync.Mutex
+	writeLock sync.Mutex
+}
+
+func newSecureConn(conn net.Conn, send *cipherState, receive *cipherState, identity ed25519.PublicKey) *SecureConn {
+	return &SecureConn{
+		Conn:      conn,
+		send:      send,
+		receive:   receive,
+		identity:  identity,
+		buffer:    nil,
+		readLock:  sync.Mutex{},
+		writeLock: sync.Mutex{},
+	}
+}
+
+func (conn *SecureConn) Read(b []byte) (int, error) {
+	conn.readLock.Lock()
+	defer conn.readLock.Unlock()
+	for len(conn.buffer) == 0 {
+		frame, err := readFrame(conn.Conn)
+		if err != nil {
+			return 0, err
+		}
+		conn.buffer, err = conn.receive.decrypt(nil, frame)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, conn.buffer)
+	conn.buffer = conn.buffer[n:]
+	return n, nil
+}
+
+func (conn *SecureConn) Write(b []byte) (int, error) {
+	conn.writeLock.Lock()
+	defer conn.writeLock.Unlock()
+	written := 0
+	for written < len(b) {
+		end := written + maxSecurePlaintext
+		if end > len(b) {
+			end = len(b)
+		}
+		err := writeFrame(conn.Conn, conn.send.encrypt(nil, b[written:end]))
+		if err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// RemoteIdentity returns the identity key the remote node proved ownership of during the handshake.
+func (conn *SecureConn) RemoteIdentity() ed25519.PublicKey {
+	return conn.identity
+}
+
+func (conn *SecureConn) Unwrap() net.Conn {
+	return conn.Conn
+}
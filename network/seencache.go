@@ -0,0 +1,99 @@
+package network
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+	"vicoin/internal/encoding"
+)
+
+// MessageID is the content hash of a Packet, equal for every copy of a gossiped message.
+type MessageID [sha256.Size]byte
+
+func NewMessageID(packet Packet) (MessageID, error) {
+	serializedData, err := encoding.Serialize(packet.Data)
+	if err != nil {
+		return MessageID{}, err
+	}
+	return sha256.Sum256(append([]byte{byte(packet.Instruction)}, serializedData...)), nil
+}
+
+func (id MessageID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SeenCache remembers recently seen messages, forgetting them once older than its ttl
+// or when evicted as the least recently seen to stay within its capacity.
+type SeenCache struct {
+	capacity int
+	ttl      time.Duration
+	order    *list.List // Front is the most recently seen.
+	entries  map[MessageID]*list.Element
+	lock     sync.Mutex
+}
+
+type seenEntry struct {
+	id   MessageID
+	seen time.Time
+}
+
+func NewSeenCache(capacity int, ttl time.Duration) *SeenCache {
+	return &SeenCache{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[MessageID]*list.Element),
+		lock:     sync.Mutex{},
+	}
+}
+
+// Observe records id as seen, reporting whether it had been seen already.
+func (cache *SeenCache) Observe(id MessageID) bool {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	now := time.Now()
+	cache.expire(now)
+	if element, ok := cache.entries[id]; ok {
+		element.Value.(*seenEntry).seen = now
+		cache.order.MoveToFront(element)
+		return true
+	}
+	cache.entries[id] = cache.order.PushFront(&seenEntry{id: id, seen: now})
+	for cache.order.Len() > cache.capacity {
+		cache.remove(cache.order.Back())
+	}
+	return false
+}
+
+// Contains reports whether id has been seen, without recording it.
+func (cache *SeenCache) Contains(id MessageID) bool {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.expire(time.Now())
+	_, ok := cache.entries[id]
+	return ok
+}
+
+func (cache *SeenCache) Len() int {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.expire(time.Now())
+	return cache.order.Len()
+}
+
+// Internal
+func (cache *SeenCache) expire(now time.Time) {
+	for element := cache.order.Back(); element != nil; element = cache.order.Back() {
+		if now.Sub(element.Value.(*seenEntry).seen) < cache.ttl {
+			return
+		}
+		cache.remove(element)
+	}
+}
+
+func (cache *SeenCache) remove(element *list.Element) {
+	cache.order.Remove(element)
+	delete(cache.entries, element.Value.(*seenEntry).id)
+}
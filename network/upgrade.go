@@ -0,0 +1,161 @@
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+)
+
+// Incoming net.Conns are upgraded at most maxUpgrades at once, and at most maxUpgradesPerIP
+// from the same non-loopback IP, those beyond being closed without a handshake.
+const (
+	maxUpgrades      = 64
+	maxUpgradesPerIP = 2
+)
+
+// UpgradedDialer upgrades every dialed net.Conn before handing it on.
+type UpgradedDialer struct {
+	dialer   DialerStrategy
+	upgrader UpgradeStrategy
+}
+
+func NewUpgradedDialer(dialer DialerStrategy, upgrader UpgradeStrategy) *UpgradedDialer {
+	return &UpgradedDialer{
+		dialer:   dialer,
+		upgrader: upgrader,
+	}
+}
+
+func (dialer *UpgradedDialer) Dial(addr net.Addr) (net.Conn, error) {
+	socket, err := dialer.dialer.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	upgraded, err := dialer.upgrader.Upgrade(socket, true)
+	if err != nil {
+		socket.Close()
+		return nil, err
+	}
+	return upgraded, nil
+}
+
+// UpgradedListener upgrades incoming net.Conns concurrently, so a slow remote can't stall Accept.
+// Connections failing the upgrade, or refused by the admission filter before it, are closed and never returned.
+type UpgradedListener struct {
+	listener  ListenerStrategy
+	upgrader  UpgradeStrategy
+	accepted  chan acceptResult
+	admit     func(addr net.Addr) error
+	upgrading map[string]int // By IP.
+	pending   int
+	lock      sync.Mutex
+}
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+func NewUpgradedListener(listener ListenerStrategy, upgrader UpgradeStrategy) *UpgradedListener {
+	upgraded := &UpgradedListener{
+		listener:  listener,
+		upgrader:  upgrader,
+		accepted:  make(chan acceptResult),
+		admit:     nil,
+		upgrading: make(map[string]int),
+		pending:   0,
+		lock:      sync.Mutex{},
+	}
+	go upgraded.listen()
+	return upgraded
+}
+
+func (listener *UpgradedListener) Accept() (net.Conn, error) {
+	result := <-listener.accepted
+	return result.conn, result.err
+}
+
+func (listener *UpgradedListener) Addr() net.Addr {
+	return listener.listener.Addr()
+}
+
+// SetAdmission filters incoming net.Conns before they are upgraded. Filtering is left to the
+// wrapped listener if it can, so that net.Conns are refused before any upgrade.
+func (listener *UpgradedListener) SetAdmission(admit func(addr net.Addr) error) {
+	if filter, ok := listener.listener.(AdmissionFilter); ok {
+		filter.SetAdmission(admit)
+		return
+	}
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+	listener.admit = admit
+}
+
+// Internal
+func (listener *UpgradedListener) listen() {
+	for {
+		socket, err := listener.listener.Accept()
+		if err != nil {
+			listener.accepted <- acceptResult{nil, err}
+			continue
+		}
+		if err := listener.reserve(socket.RemoteAddr()); err != nil {
+			log.Println("Refusing incoming net.Conn: ", err)
+			socket.Close()
+			continue
+		}
+		go func() {
+			defer listener.release(socket.RemoteAddr())
+			listener.upgrade(socket)
+		}()
+	}
+}
+
+// reserve admits an upgrade of a net.Conn from addr, if it passes the filter and the upgrades in progress allow.
+func (listener *UpgradedListener) reserve(addr net.Addr) error {
+	listener.lock.Lock()
+	admit := listener.admit
+	listener.lock.Unlock()
+	if admit != nil {
+		if err := admit(addr); err != nil {
+			return err
+		}
+	}
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+	if listener.pending >= maxUpgrades {
+		return fmt.Errorf("%w: %d upgrades in progress", ErrConnectionLimit, maxUpgrades)
+	}
+	ip := ipOf(addr)
+	if ip != nil && !ip.IsLoopback() {
+		if listener.upgrading[ip.String()] >= maxUpgradesPerIP {
+			return fmt.Errorf("%w: %d upgrades in progress per IP", ErrConnectionLimit, maxUpgradesPerIP)
+		}
+		listener.upgrading[ip.String()]++
+	}
+	listener.pending++
+	return nil
+}
+
+func (listener *UpgradedListener) release(addr net.Addr) {
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+	listener.pending--
+	if ip := ipOf(addr); ip != nil && !ip.IsLoopback() {
+		listener.upgrading[ip.String()]--
+		if listener.upgrading[ip.String()] == 0 {
+			delete(listener.upgrading, ip.String())
+		}
+	}
+}
+
+func (listener *UpgradedListener) upgrade(socket net.Conn) {
+	upgraded, err := listener.upgrader.Upgrade(socket, false)
+	if err != nil {
+		log.Println("Upgrade of incoming net.Conn failed: ", err)
+		socket.Close()
+		return
+	}
+	listener.accepted <- acceptResult{upgraded, nil}
+}
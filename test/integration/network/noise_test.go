@@ -0,0 +1,44 @@
+package network_test
+
+import (
+	"testing"
+	"time"
+	"vicoin/network"
+)
+
+func makeSecureDependencies() (chan interface{}, network.DialerStrategy, network.ListenerStrategy) {
+	channel, dialer, listener := makeDependencies()
+	key, _ := network.NewNodeKey()
+	noise := network.NewNoiseUpgrader(key, time.Second)
+	hello := network.NewHelloUpgrader(network.NewHello(key.ID(), listener.Addr(), network.DefaultChainID), time.Second)
+	secureDialer := network.NewUpgradedDialer(network.NewUpgradedDialer(dialer, noise), hello)
+	secureListener := network.NewUpgradedListener(network.NewUpgradedListener(listener, noise), hello)
+	return channel, secureDialer, secureListener
+}
+
+func TestSecurePolysocketsExchangeMessages(t *testing.T) {
+	channel1, dialer1, listener1 := makeSecureDependencies()
+	poly1 := network.NewPolysocket(channel1, dialer1, listener1)
+	channel2, dialer2, listener2 := makeSecureDependencies()
+	poly2 := network.NewPolysocket(channel2, dialer2, listener2)
+	conn, err := poly2.Connect(poly1.GetAddr())
+	if err != nil {
+		t.Fatal("Error when connecting: ", err)
+	}
+	if _, ok := network.RemoteIdentity(conn); !ok {
+		t.Error("Dialed net.Conn doesn't know the remote identity")
+	}
+	if _, ok := network.RemoteHello(conn); !ok {
+		t.Error("Dialed net.Conn doesn't know the remote hello")
+	}
+	sent := []string{"lorem ipsum", "ipsum lorem"}
+	for _, msg := range sent {
+		poly2.Send(msg, conn.RemoteAddr())
+	}
+	for _, msg := range sent {
+		received := <-channel1
+		if received != msg {
+			t.Errorf("Received (%s) message doesn't equal the sent (%s) message", received, msg)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package network_test
 
 import (
+	"errors"
 	"net"
+	"strings"
 	"testing"
 	"time"
 	"vicoin/network"
@@ -9,7 +11,7 @@ import (
 
 func makeDependencies() (chan interface{}, network.DialerStrategy, network.ListenerStrategy) {
 	dialer, _ := network.NewTCPDialer()
-	listener, _ := network.NewTCPListener()
+	listener, _ := network.NewTCPListener(":0")
 	return make(chan interface{}), dialer, listener
 }
 
@@ -70,3 +72,66 @@ func TestPolysocketsSendsOnlyToTarget(t *testing.T) {
 		t.Errorf("Received (%d) message doesn't equal the sent (ipsum lorem) message", received)
 	}
 }
+
+type refusingEviction struct{}
+
+func (refusingEviction) Evict(candidates []network.PeerStats) (net.Addr, bool) {
+	return nil, false
+}
+
+func TestPolysocketsRefuseConnectionsBeyondTheirLimits(t *testing.T) {
+	channel1, dialer1, listener1 := makeDependencies()
+	poly1 := network.NewPolysocket(channel1, dialer1, listener1)
+	poly1.SetLimits(network.ConnectionLimits{MaxInbound: 1})
+	poly1.SetEvictionStrategy(refusingEviction{})
+	channel2, dialer2, listener2 := makeDependencies()
+	poly2 := network.NewPolysocket(channel2, dialer2, listener2)
+	poly2.SetLimits(network.ConnectionLimits{MaxOutbound: 1})
+	channel3, dialer3, listener3 := makeDependencies()
+	poly3 := network.NewPolysocket(channel3, dialer3, listener3)
+	poly2.Connect(poly1.GetAddr())
+	if _, err := poly2.Connect(poly3.GetAddr()); !errors.Is(err, network.ErrConnectionLimit) {
+		t.Errorf("Unexpected error %v, want ErrConnectionLimit", err)
+	}
+	poly3.Connect(poly1.GetAddr())
+	time.Sleep(50 * time.Millisecond)
+	if len(poly1.GetConnections()) != 1 {
+		t.Errorf("Unexpected # of connections %d, want 1", len(poly1.GetConnections()))
+	}
+}
+
+func TestPolysocketsEvictInboundConnectionsForNewOnes(t *testing.T) {
+	channel1, dialer1, listener1 := makeDependencies()
+	poly1 := network.NewPolysocket(channel1, dialer1, listener1)
+	poly1.SetLimits(network.ConnectionLimits{MaxInbound: 1})
+	poly1.SetEvictionStrategy(network.ProtectingEviction{Protect: 0})
+	channel2, dialer2, listener2 := makeDependencies()
+	poly2 := network.NewPolysocket(channel2, dialer2, listener2)
+	channel3, dialer3, listener3 := makeDependencies()
+	poly3 := network.NewPolysocket(channel3, dialer3, listener3)
+	poly2.Connect(poly1.GetAddr())
+	time.Sleep(50 * time.Millisecond)
+	conn3, _ := poly3.Connect(poly1.GetAddr())
+	time.Sleep(50 * time.Millisecond)
+	connections := poly1.GetConnections()
+	if len(connections) != 1 {
+		t.Fatalf("Unexpected # of connections %d, want 1", len(connections))
+	}
+	if _, ok := connections[conn3.LocalAddr().String()]; !ok {
+		t.Error("New connection wasn't kept")
+	}
+}
+
+func TestPolysocketsDropConnectionsSendingOversizedMessages(t *testing.T) {
+	channel1, dialer1, listener1 := makeDependencies()
+	poly1 := network.NewPolysocket(channel1, dialer1, listener1)
+	channel2, dialer2, listener2 := makeDependencies()
+	poly2 := network.NewPolysocket(channel2, dialer2, listener2)
+	conn, _ := poly2.Connect(poly1.GetAddr())
+	time.Sleep(50 * time.Millisecond)
+	go poly2.Send(strings.Repeat("a", 2*network.MaxMessageSize), conn.RemoteAddr())
+	time.Sleep(100 * time.Millisecond)
+	if len(poly1.GetConnections()) != 0 {
+		t.Errorf("Unexpected # of connections %d, want 0", len(poly1.GetConnections()))
+	}
+}
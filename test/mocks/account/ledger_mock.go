@@ -27,3 +27,69 @@ func (mock *MockLedger) SignedTransaction(transaction *account.SignedTransaction
 func (mock *MockLedger) GetBalance(account string) float64 {
 	return 42
 }
+
+func (mock *MockLedger) Height() int {
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+	return len(mock.Transactions)
+}
+
+func (mock *MockLedger) History(from int, limit int) []account.SignedTransaction {
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+	history := make([]account.SignedTransaction, 0)
+	for i := from; i < len(mock.Transactions) && i < from+limit; i++ {
+		history = append(history, *mock.Transactions[i])
+	}
+	return history
+}
+
+func (mock *MockLedger) Summary() account.Summary {
+	return account.Summary{}
+}
+
+func (mock *MockLedger) HashesIn(buckets []int) []account.TransactionHash {
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+	hashes := make([]account.TransactionHash, 0)
+	for _, transaction := range mock.Transactions {
+		hash := transaction.Hash()
+		for _, bucket := range buckets {
+			if int(hash[0]) == bucket {
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+	return hashes
+}
+
+func (mock *MockLedger) Lookup(hashes []account.TransactionHash) []account.SignedTransaction {
+	mock.lock.Lock()
+	defer mock.lock.Unlock()
+	transactions := make([]account.SignedTransaction, 0)
+	for _, transaction := range mock.Transactions {
+		hash := transaction.Hash()
+		for _, wanted := range hashes {
+			if hash == wanted {
+				transactions = append(transactions, *transaction)
+			}
+		}
+	}
+	return transactions
+}
+
+func (mock *MockLedger) StateRoot() account.StateHash {
+	return account.StateHash{}
+}
+
+func (mock *MockLedger) Prove(acc string) account.StateProof {
+	return account.StateProof{Account: acc}
+}
+
+func (mock *MockLedger) Digest() account.StateDigest {
+	return account.StateDigest{Height: mock.Height()}
+}
+
+func (mock *MockLedger) Params() account.GenesisParams {
+	return account.DefaultGenesis().Params
+}
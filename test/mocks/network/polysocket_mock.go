@@ -7,9 +7,13 @@ import (
 
 type MockPolysocket struct {
 	SentMessages        []interface{}
+	SentTo              []net.Addr
 	BroadcastedMessages []interface{}
 	Channel             chan interface{}
 	Connections         []net.Addr
+	Conns               map[string]net.Conn
+	Disconnected        []net.Addr
+	dropHandlers        []func(addr net.Addr)
 	lock                sync.Mutex
 }
 
@@ -30,6 +34,7 @@ func (pm *MockPolysocket) Send(data interface{}, addr net.Addr) error {
 	pm.lock.Lock()
 	defer pm.lock.Unlock()
 	pm.SentMessages = append(pm.SentMessages, data)
+	pm.SentTo = append(pm.SentTo, addr)
 	return nil
 }
 
@@ -47,3 +52,73 @@ func (pm *MockPolysocket) GetAddr() net.Addr {
 		Zone: "",
 	}
 }
+
+func (pm *MockPolysocket) GetConnections() map[string]net.Conn {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	conns := make(map[string]net.Conn)
+	for key, conn := range pm.Conns {
+		conns[key] = conn
+	}
+	return conns
+}
+
+func (pm *MockPolysocket) Disconnect(addr net.Addr) error {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	pm.Disconnected = append(pm.Disconnected, addr)
+	for key, conn := range pm.Conns {
+		if conn.RemoteAddr().String() == addr.String() {
+			delete(pm.Conns, key)
+		}
+	}
+	return nil
+}
+
+func (pm *MockPolysocket) OnDrop(handler func(addr net.Addr)) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	pm.dropHandlers = append(pm.dropHandlers, handler)
+}
+
+// Drop loses the connection to addr as a remote disconnect would, notifying OnDrop handlers.
+func (pm *MockPolysocket) Drop(addr net.Addr) {
+	pm.lock.Lock()
+	for key, conn := range pm.Conns {
+		if conn.RemoteAddr().String() == addr.String() {
+			delete(pm.Conns, key)
+		}
+	}
+	handlers := append([]func(addr net.Addr){}, pm.dropHandlers...)
+	pm.lock.Unlock()
+	for _, handler := range handlers {
+		handler(addr)
+	}
+}
+
+// MockConn is a net.Conn with a given remote address, for filling Conns.
+type MockConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func NewMockConn(remote net.Addr) *MockConn {
+	local, _ := net.Pipe()
+	return WrapMockConn(local, remote)
+}
+
+// WrapMockConn gives conn the remote address remote, keeping what was negotiated on it.
+func WrapMockConn(conn net.Conn, remote net.Addr) *MockConn {
+	return &MockConn{
+		Conn:   conn,
+		remote: remote,
+	}
+}
+
+func (conn *MockConn) Unwrap() net.Conn {
+	return conn.Conn
+}
+
+func (conn *MockConn) RemoteAddr() net.Addr {
+	return conn.remote
+}
@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	"net"
+	"vicoin/network"
+)
+
+// RPCSocket delivers the requests and responses sent straight to the RPC of its remote, marked as coming from Addr.
+type RPCSocket struct {
+	Addr   net.Addr
+	Remote *network.RPC
+	Silent bool // Drop everything sent.
+}
+
+// NewRPCPair returns the RPC of a caller and of the callee at the returned address, connected to each other.
+func NewRPCPair() (*network.RPC, *network.RPC, net.Addr) {
+	callerSocket := &RPCSocket{Addr: &net.TCPAddr{Port: 4242}}
+	calleeSocket := &RPCSocket{Addr: &net.TCPAddr{Port: 4343}}
+	caller := network.NewRPC(callerSocket)
+	callee := network.NewRPC(calleeSocket)
+	callerSocket.Remote = callee
+	calleeSocket.Remote = caller
+	return caller, callee, calleeSocket.Addr
+}
+
+func (socket *RPCSocket) Connect(addr net.Addr) (net.Conn, error) { return nil, nil }
+func (socket *RPCSocket) Close() []error                          { return nil }
+func (socket *RPCSocket) Broadcast(data interface{}) []error      { return nil }
+func (socket *RPCSocket) GetAddr() net.Addr                       { return socket.Addr }
+func (socket *RPCSocket) GetConnections() map[string]net.Conn     { return nil }
+func (socket *RPCSocket) Disconnect(addr net.Addr) error          { return nil }
+
+func (socket *RPCSocket) Send(data interface{}, addr net.Addr) error {
+	if socket.Silent {
+		return nil
+	}
+	packet := data.(network.Packet).WithOrigin(socket.Addr)
+	switch payload := packet.Data.(type) {
+	case network.RPCRequest:
+		socket.Remote.HandleRequest(packet, payload)
+	case network.RPCResponse:
+		socket.Remote.HandleResponse(packet, payload)
+	}
+	return nil
+}
@@ -4,17 +4,22 @@ import (
 	"net"
 	"sync"
 	"vicoin/internal/account"
+	"vicoin/network"
 )
 
 type MockNode struct {
-	sent []*account.SignedTransaction
-	lock sync.Mutex
+	Rpc       *network.RPC
+	SyncPeers []net.Addr
+	sent      []*account.SignedTransaction
+	lock      sync.Mutex
 }
 
 func NewMockNode() *MockNode {
 	return &MockNode{
-		sent: make([]*account.SignedTransaction, 0),
-		lock: sync.Mutex{},
+		Rpc:       nil,
+		SyncPeers: make([]net.Addr, 0),
+		sent:      make([]*account.SignedTransaction, 0),
+		lock:      sync.Mutex{},
 	}
 }
 
@@ -33,3 +38,15 @@ func (mock *MockNode) SendTransaction(transaction account.SignedTransaction) {
 func (mock *MockNode) GetAddr() net.Addr {
 	return &net.TCPAddr{}
 }
+
+func (mock *MockNode) GetAdvertisedAddrs() []net.Addr {
+	return []net.Addr{&net.TCPAddr{}}
+}
+
+func (mock *MockNode) GetSyncPeers() []net.Addr {
+	return mock.SyncPeers
+}
+
+func (mock *MockNode) RPC() *network.RPC {
+	return mock.Rpc
+}
@@ -0,0 +1,74 @@
+package account_test
+
+import (
+	"errors"
+	"testing"
+	"vicoin/crypto"
+	"vicoin/internal/account"
+	"vicoin/internal/registration"
+)
+
+func TestLedgersApplyBatchesAtomically(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	ledger := account.NewLedger()
+	ledger.SetBalance(sender, 100)
+	payroll, _ := account.NewSignedBatch("1", sender, []account.Output{{To: "alice", Amount: 30}, {To: "bob", Amount: 20}}, 1, private)
+	if err := ledger.SignedTransaction(payroll); err != nil {
+		t.Fatal("Error when applying batch: ", err)
+	}
+	if ledger.GetBalance(sender) != 49 || ledger.GetBalance("alice") != 30 || ledger.GetBalance("bob") != 20 {
+		t.Errorf("Unexpected balances %f, %f and %f, want 49, 30 and 20", ledger.GetBalance(sender), ledger.GetBalance("alice"), ledger.GetBalance("bob"))
+	}
+	excessive, _ := account.NewSignedBatch("2", sender, []account.Output{{To: "alice", Amount: 30}, {To: "bob", Amount: 30}}, 0, private)
+	err := ledger.SignedTransaction(excessive)
+	var batchErr *account.BatchError
+	if !errors.Is(err, account.ErrInsufficientFunds) || !errors.As(err, &batchErr) {
+		t.Fatalf("Unexpected error %v, want a BatchError for insufficient funds", err)
+	}
+	if !errors.Is(batchErr.Results[0].Err, account.ErrBatchAborted) || !errors.Is(batchErr.Results[1].Err, account.ErrInsufficientFunds) {
+		t.Errorf("Unexpected results %v, want the second output to exceed the funds", batchErr.Results)
+	}
+	if ledger.GetBalance(sender) != 49 || ledger.GetBalance("alice") != 30 || ledger.Height() != 1 {
+		t.Errorf("Unexpected balances %f and %f after a failed batch, want 49 and 30", ledger.GetBalance(sender), ledger.GetBalance("alice"))
+	}
+}
+
+func TestLedgersReportInvalidOutputsOfBatches(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	ledger := account.NewLedger()
+	ledger.SetBalance(sender, 100)
+	invalid, _ := account.NewSignedBatch("1", sender, []account.Output{{To: "alice", Amount: 10}, {To: "", Amount: 10}, {To: "bob", Amount: -10}}, 0, private)
+	var batchErr *account.BatchError
+	if err := ledger.SignedTransaction(invalid); !errors.As(err, &batchErr) {
+		t.Fatalf("Unexpected error %v, want a BatchError", err)
+	}
+	want := []error{account.ErrBatchAborted, account.ErrMissingRecipient, account.ErrInvalidAmount}
+	for i, result := range batchErr.Results {
+		if !errors.Is(result.Err, want[i]) {
+			t.Errorf("Unexpected result %v of output %d, want %v", result.Err, i, want[i])
+		}
+	}
+	if ledger.GetBalance("alice") != 0 {
+		t.Errorf("Unexpected balance %f after an invalid batch, want 0", ledger.GetBalance("alice"))
+	}
+}
+
+func TestPaymentsAreValidatedWithoutALedger(t *testing.T) {
+	single := account.SignedTransaction{ID: "1", From: "sender", Amount: 10}
+	if err := account.ValidatePayments(&single); !errors.Is(err, account.ErrMissingRecipient) {
+		t.Errorf("Unexpected error %v for a transfer without recipient, want ErrMissingRecipient", err)
+	}
+	batch := account.SignedTransaction{ID: "2", From: "sender", Outputs: []account.Output{{To: "alice", Amount: 10}, {To: "bob", Amount: 0}}}
+	var batchErr *account.BatchError
+	if err := account.ValidatePayments(&batch); !errors.As(err, &batchErr) || !errors.Is(batchErr.Results[1].Err, account.ErrInvalidAmount) {
+		t.Errorf("Unexpected error %v for a batch paying nothing to bob, want a BatchError", err)
+	}
+	batch.Outputs[1].Amount = 10
+	if err := account.ValidatePayments(&batch); err != nil {
+		t.Error("Error when validating a valid batch: ", err)
+	}
+}
@@ -0,0 +1,69 @@
+package account_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"vicoin/crypto"
+	"vicoin/internal/account"
+	"vicoin/internal/registration"
+)
+
+func TestGenesesAreLoadedAndHashedIntoTheChainID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	os.WriteFile(path, []byte(`{"NetworkID": "testnet", "Allocations": [{"Account": "alice", "Balance": 100}], "Params": {"MaxSupply": 1000}}`), 0644)
+	genesis, err := account.LoadGenesis(path)
+	if err != nil {
+		t.Fatal("Error when loading genesis: ", err)
+	}
+	ledger := account.NewGenesisLedger(genesis)
+	if ledger.GetBalance("alice") != 100 || ledger.Supply() != 100 {
+		t.Errorf("Unexpected balance %f and supply %f, want 100 and 100", ledger.GetBalance("alice"), ledger.Supply())
+	}
+	other := *genesis
+	other.Allocations = []account.Allocation{{Account: "alice", Balance: 101}}
+	if genesis.ChainID() == other.ChainID() || genesis.ChainID() == account.DefaultGenesis().ChainID() {
+		t.Errorf("Unexpected chain ID %s shared by different geneses", genesis.ChainID())
+	}
+}
+
+func TestGenesesRejectInvalidAllocations(t *testing.T) {
+	geneses := []account.Genesis{
+		{NetworkID: ""},
+		{NetworkID: "testnet", Allocations: []account.Allocation{{Account: "alice", Balance: 1}, {Account: "alice", Balance: 1}}},
+		{NetworkID: "testnet", Allocations: []account.Allocation{{Account: "alice", Balance: -1}}},
+		{NetworkID: "testnet", Allocations: []account.Allocation{{Account: "alice", Balance: 11}}, Params: account.GenesisParams{MaxSupply: 10}},
+	}
+	for _, genesis := range geneses {
+		if genesis.Validate() == nil {
+			t.Errorf("Expected an error for genesis %v", genesis)
+		}
+	}
+}
+
+func TestLedgersOnlyAcceptIssuancesOfMintersWithinTheMaximumSupply(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	minter, _ := public.ToString()
+	ledger := account.NewGenesisLedger(&account.Genesis{NetworkID: "testnet", Minters: []string{minter}, Params: account.GenesisParams{MaxSupply: 50}})
+	issuance, _ := account.NewSignedIssuance("1", minter, "alice", 40, private)
+	if err := ledger.SignedTransaction(issuance); err != nil || ledger.GetBalance("alice") != 40 {
+		t.Errorf("Unexpected balance %f after issuance (%v), want 40", ledger.GetBalance("alice"), err)
+	}
+	excessive, _ := account.NewSignedIssuance("2", minter, "alice", 20, private)
+	if err := ledger.SignedTransaction(excessive); !errors.Is(err, account.ErrSupplyExceeded) {
+		t.Errorf("Unexpected error %v, want ErrSupplyExceeded", err)
+	}
+	negative, _ := account.NewSignedTransaction("3", minter, "alice", -10, private)
+	if err := ledger.SignedTransaction(negative); !errors.Is(err, account.ErrInvalidAmount) {
+		t.Errorf("Unexpected error %v, want ErrInvalidAmount", err)
+	}
+	unauthorized := account.NewLedger()
+	if err := unauthorized.SignedTransaction(issuance); !errors.Is(err, account.ErrUnauthorizedIssuance) {
+		t.Errorf("Unexpected error %v, want ErrUnauthorizedIssuance", err)
+	}
+	if unauthorized.Supply() != 0 {
+		t.Errorf("Unexpected supply %f, want 0", unauthorized.Supply())
+	}
+}
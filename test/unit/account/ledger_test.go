@@ -1,6 +1,8 @@
 package account_test
 
 import (
+	"errors"
+	"math/big"
 	"testing"
 	"vicoin/crypto"
 	"vicoin/internal/account"
@@ -51,3 +53,75 @@ func TestLedgersCantPerformTransactionsThatViolateBalance(t *testing.T) {
 		t.Error("Error: allowed illegitemate transaction ")
 	}
 }
+
+func TestLedgersKeepHistoryAndRefuseDuplicates(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	ledger := account.NewLedger()
+	public, private, _ := crypto.KeyGen(2048)
+	senderAccount, _ := public.ToString()
+	ledger.SetBalance(senderAccount, 42)
+	first, _ := account.NewSignedTransaction("1", senderAccount, "recipient", 10, private)
+	second, _ := account.NewSignedTransaction("2", senderAccount, "recipient", 10, private)
+	ledger.SignedTransaction(first)
+	ledger.SignedTransaction(second)
+	if err := ledger.SignedTransaction(first); !errors.Is(err, account.ErrDuplicateTransaction) {
+		t.Errorf("Unexpected error %v, want ErrDuplicateTransaction", err)
+	}
+	if ledger.Height() != 2 || ledger.GetBalance(senderAccount) != 22 {
+		t.Errorf("Unexpected height %d and balance %f, want 2 and 22", ledger.Height(), ledger.GetBalance(senderAccount))
+	}
+	history := ledger.History(1, 10)
+	if len(history) != 1 || history[0].ID != "2" {
+		t.Errorf("Unexpected history %v, want the second transaction", history)
+	}
+}
+
+func TestLedgersRefuseDuplicatesWithMalleatedSignatures(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	ledger := account.NewLedger()
+	public, private, _ := crypto.KeyGen(2048)
+	senderAccount, _ := public.ToString()
+	ledger.SetBalance(senderAccount, 42)
+	transaction, _ := account.NewSignedTransaction("1", senderAccount, "recipient", 10, private)
+	ledger.SignedTransaction(transaction)
+	malleated := *transaction
+	signature := new(big.Int).SetBytes([]byte(transaction.Signature))
+	malleated.Signature = string(signature.Add(signature, public.N).Bytes())
+	if err := malleated.Verify(); err != nil {
+		t.Fatalf("Malleated signature didn't verify : %v", err)
+	}
+	if err := ledger.SignedTransaction(&malleated); !errors.Is(err, account.ErrDuplicateTransaction) {
+		t.Errorf("Unexpected error %v, want ErrDuplicateTransaction", err)
+	}
+	if ledger.GetBalance(senderAccount) != 32 {
+		t.Errorf("Unexpected balance %f, want 32", ledger.GetBalance(senderAccount))
+	}
+}
+
+func TestLedgersSummarizeTransactionsRegardlessOfOrder(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	senderAccount, _ := public.ToString()
+	first, _ := account.NewSignedTransaction("1", senderAccount, "recipient", 10, private)
+	second, _ := account.NewSignedTransaction("2", senderAccount, "recipient", 10, private)
+	ledger, other := account.NewLedger(), account.NewLedger()
+	ledger.SetBalance(senderAccount, 42)
+	other.SetBalance(senderAccount, 42)
+	ledger.SignedTransaction(first)
+	other.SignedTransaction(second)
+	differences := ledger.Summary().Differences(other.Summary())
+	if len(differences) == 0 || len(differences) > 2 {
+		t.Errorf("Unexpected differing buckets %v, want those of both transactions", differences)
+	}
+	ledger.SignedTransaction(second)
+	other.SignedTransaction(first)
+	if differences := ledger.Summary().Differences(other.Summary()); len(differences) != 0 {
+		t.Errorf("Unexpected differing buckets %v, want none", differences)
+	}
+	if hashes := ledger.HashesIn([]int{int(first.Hash()[0])}); len(hashes) == 0 {
+		t.Error("Missing the hash of the first transaction in its bucket")
+	}
+	if found := ledger.Lookup([]account.TransactionHash{second.Hash()}); len(found) != 1 || found[0].ID != "2" {
+		t.Errorf("Unexpected lookup %v, want the second transaction", found)
+	}
+}
@@ -0,0 +1,93 @@
+package account_test
+
+import (
+	"errors"
+	"testing"
+	"vicoin/crypto"
+	"vicoin/internal/account"
+	"vicoin/internal/registration"
+)
+
+func TestLedgersChargeFeesToTheCollectorOrBurnThem(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	collected := account.NewGenesisLedger(&account.Genesis{NetworkID: "testnet", Allocations: []account.Allocation{{Account: sender, Balance: 100}}, Params: account.GenesisParams{FeeCollector: "producer"}})
+	burned := account.NewGenesisLedger(&account.Genesis{NetworkID: "testnet", Allocations: []account.Allocation{{Account: sender, Balance: 100}}})
+	transaction, _ := account.NewSignedTransactionWithFee("1", sender, "recipient", 10, 2, private)
+	collected.SignedTransaction(transaction)
+	burned.SignedTransaction(transaction)
+	if collected.GetBalance(sender) != 88 || collected.GetBalance("producer") != 2 || collected.Supply() != 100 {
+		t.Errorf("Unexpected balances %f and %f with supply %f, want 88 and 2 with 100", collected.GetBalance(sender), collected.GetBalance("producer"), collected.Supply())
+	}
+	if burned.GetBalance(sender) != 88 || burned.Supply() != 98 {
+		t.Errorf("Unexpected balance %f with supply %f, want 88 with 98", burned.GetBalance(sender), burned.Supply())
+	}
+	expensive, _ := account.NewSignedTransactionWithFee("2", sender, "recipient", 80, 10, private)
+	if err := burned.SignedTransaction(expensive); !errors.Is(err, account.ErrInsufficientFunds) {
+		t.Errorf("Unexpected error %v for funds not covering the fee, want ErrInsufficientFunds", err)
+	}
+}
+
+func TestLedgersRefuseFeeRatesBelowTheMinimum(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	ledger := account.NewGenesisLedger(&account.Genesis{NetworkID: "testnet", Allocations: []account.Allocation{{Account: sender, Balance: 100}}, Params: account.GenesisParams{MinFeeRate: 0.001}})
+	free, _ := account.NewSignedTransaction("1", sender, "recipient", 10, private)
+	if err := ledger.SignedTransaction(free); !errors.Is(err, account.ErrFeeTooLow) {
+		t.Errorf("Unexpected error %v, want ErrFeeTooLow", err)
+	}
+	paying, _ := account.NewSignedTransactionWithFee("2", sender, "recipient", 10, 0.001*float64(free.Size()+1), private)
+	if err := ledger.SignedTransaction(paying); err != nil {
+		t.Error("Error when paying the minimum fee rate: ", err)
+	}
+}
+
+func TestMempoolsHandOutTransactionsByFeeRateAndEvictTheCheapest(t *testing.T) {
+	if _, err := account.NewMempool(0); err == nil {
+		t.Error("Expected an error for a mempool without capacity")
+	}
+	mempool, _ := account.NewMempool(3)
+	for i, fee := range []float64{1, 3, 2} {
+		mempool.Add(account.SignedTransaction{ID: string(rune('a' + i)), From: "sender", To: "recipient", Amount: 1, Fee: fee, Signature: "signature"})
+	}
+	if err := mempool.Add(account.SignedTransaction{ID: "d", Fee: 0.5, Signature: "signature"}); !errors.Is(err, account.ErrMempoolFull) {
+		t.Errorf("Unexpected error %v, want ErrMempoolFull", err)
+	}
+	mempool.Add(account.SignedTransaction{ID: "e", From: "sender", To: "recipient", Amount: 1, Fee: 4, Signature: "signature"})
+	pending := mempool.Pending()
+	if len(pending) != 3 || pending[0].ID != "e" || pending[1].ID != "b" || pending[2].ID != "c" {
+		t.Errorf("Unexpected pending transactions %v, want e, b and c", pending)
+	}
+	mempool.Add(account.SignedTransaction{ID: "f", From: "other", To: "recipient", Amount: 1, Fee: 5, Signature: "signature"})
+	if sent := mempool.PendingFrom("sender"); len(sent) != 2 || sent[0].ID != "e" || sent[1].ID != "b" {
+		t.Errorf("Unexpected transactions pending from sender %v, want e and b", sent)
+	}
+	mempool.Remove(pending[0].Hash())
+	if senders := mempool.Senders(); len(senders) != 2 || len(mempool.PendingFrom("sender")) != 1 {
+		t.Errorf("Unexpected senders %v once removed, want other and sender", senders)
+	}
+}
+
+func TestFeeEstimatorsTakeTheMedianRateOfTheTransactionsAppliedLast(t *testing.T) {
+	if _, err := account.NewFeeEstimator(0); err == nil {
+		t.Error("Expected an error for an estimator over no transactions")
+	}
+	estimator, _ := account.NewFeeEstimator(3)
+	if rate := estimator.Estimate(0.25); rate != 0.25 {
+		t.Errorf("Unexpected estimated fee rate %f before any transaction, want the minimum", rate)
+	}
+	transactions := make([]account.SignedTransaction, 0)
+	for i, fee := range []float64{100, 1, 3, 2} {
+		transactions = append(transactions, account.SignedTransaction{ID: string(rune('a' + i)), From: "sender", To: "recipient", Amount: 1, Fee: fee, Signature: "signature"})
+		estimator.Record(&transactions[i])
+	}
+	estimator.Record(&account.SignedTransaction{ID: "e", To: "recipient", Amount: 1, Issue: true})
+	if rate := estimator.Estimate(0); rate != transactions[3].FeeRate() {
+		t.Errorf("Unexpected estimated fee rate %f, want the median of the last 3 transactions %f", rate, transactions[3].FeeRate())
+	}
+	if rate := estimator.Estimate(1); rate != 1 {
+		t.Errorf("Unexpected estimated fee rate %f below the minimum, want the minimum", rate)
+	}
+}
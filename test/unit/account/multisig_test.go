@@ -0,0 +1,111 @@
+package account_test
+
+import (
+	"errors"
+	"testing"
+	"vicoin/crypto"
+	"vicoin/internal/account"
+	"vicoin/internal/registration"
+)
+
+type signer struct {
+	public  *crypto.PublicKey
+	private *crypto.PrivateKey
+}
+
+func treasury(t *testing.T, threshold int, n int) (account.MultisigPolicy, []signer) {
+	signers := make([]signer, n)
+	keys := make([]string, n)
+	for i := range signers {
+		public, private, _ := crypto.KeyGen(2048)
+		signers[i] = signer{public: public, private: private}
+		keys[i], _ = public.ToString()
+	}
+	policy := account.MultisigPolicy{Threshold: threshold, Keys: keys}
+	if err := policy.Validate(); err != nil {
+		t.Fatal("Error when validating policy: ", err)
+	}
+	return policy, signers
+}
+
+func TestLedgersRequireTheThresholdOfMultisigAccounts(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	policy, signers := treasury(t, 2, 3)
+	ledger := account.NewLedger()
+	ledger.SetBalance(policy.Account(), 100)
+	transaction, err := account.NewMultisigTransaction(account.Transaction{ID: "1", To: "alice", Amount: 40}, policy)
+	if err != nil {
+		t.Fatal("Error when creating transaction: ", err)
+	}
+	if err := transaction.AddSignature(signers[0].public, signers[0].private); err != nil {
+		t.Fatal("Error when signing: ", err)
+	}
+	if err := ledger.SignedTransaction(transaction); !errors.Is(err, account.ErrThresholdNotMet) {
+		t.Fatalf("Unexpected error %v with 1 of 2 signatures, want ErrThresholdNotMet", err)
+	}
+	// The second key holder signs their own copy, offline.
+	encoded, _ := transaction.ToString()
+	copied, err := new(account.SignedTransaction).FromString(encoded)
+	if err != nil {
+		t.Fatal("Error when decoding transaction: ", err)
+	}
+	if err := copied.AddSignature(signers[2].public, signers[2].private); err != nil {
+		t.Fatal("Error when signing: ", err)
+	}
+	if err := transaction.Combine(copied); err != nil {
+		t.Fatal("Error when combining signatures: ", err)
+	}
+	if transaction.Signers() != 2 {
+		t.Errorf("Unexpected %d signers, want 2", transaction.Signers())
+	}
+	if err := ledger.SignedTransaction(transaction); err != nil {
+		t.Fatal("Error when applying transaction: ", err)
+	}
+	if ledger.GetBalance(policy.Account()) != 60 || ledger.GetBalance("alice") != 40 {
+		t.Errorf("Unexpected balances %f and %f, want 60 and 40", ledger.GetBalance(policy.Account()), ledger.GetBalance("alice"))
+	}
+	if err := ledger.SignedTransaction(copied); !errors.Is(err, account.ErrDuplicateTransaction) {
+		t.Errorf("Unexpected error %v for the same transaction signed by other keys, want ErrDuplicateTransaction", err)
+	}
+}
+
+func TestMultisigTransactionsOnlyAcceptKeysOfTheirPolicy(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	policy, signers := treasury(t, 1, 2)
+	outsider, outsiderPrivate, _ := crypto.KeyGen(2048)
+	transaction, _ := account.NewMultisigTransaction(account.Transaction{ID: "1", To: "alice", Amount: 10}, policy)
+	if err := transaction.AddSignature(outsider, outsiderPrivate); !errors.Is(err, account.ErrNotASigner) {
+		t.Errorf("Unexpected error %v signing with an outsider key, want ErrNotASigner", err)
+	}
+	// A signature by the wrong key doesn't count.
+	signature, _ := crypto.Sign(account.Transaction{ID: "1", From: policy.Account(), To: "alice", Amount: 10}, signers[1].private)
+	transaction.Signatures[0] = string(signature)
+	if transaction.Signers() != 0 {
+		t.Errorf("Unexpected %d signers with a misplaced signature, want 0", transaction.Signers())
+	}
+	other, _ := treasury(t, 1, 2)
+	ledger := account.NewLedger()
+	ledger.SetBalance(policy.Account(), 100)
+	transaction.AddSignature(signers[0].public, signers[0].private)
+	transaction.Multisig = &other
+	if err := ledger.SignedTransaction(transaction); err == nil {
+		t.Error("Unexpected success spending with another policy than the sender's")
+	}
+	if ledger.GetBalance("alice") != 0 {
+		t.Errorf("Unexpected balance %f, want 0", ledger.GetBalance("alice"))
+	}
+}
+
+func TestMultisigPoliciesAreValidated(t *testing.T) {
+	policies := []account.MultisigPolicy{
+		{Threshold: 1, Keys: []string{}},
+		{Threshold: 0, Keys: []string{"a"}},
+		{Threshold: 3, Keys: []string{"a", "b"}},
+		{Threshold: 1, Keys: []string{"a", "a"}},
+	}
+	for _, policy := range policies {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("Unexpected valid policy %v", policy)
+		}
+	}
+}
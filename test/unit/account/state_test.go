@@ -0,0 +1,79 @@
+package account_test
+
+import (
+	"errors"
+	"testing"
+	"vicoin/crypto"
+	"vicoin/internal/account"
+	"vicoin/internal/registration"
+)
+
+func TestLedgersAgreeOnStateRootRegardlessOfOrder(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	first, _ := account.NewSignedTransaction("1", sender, "alice", 10, private)
+	second, _ := account.NewSignedTransaction("2", sender, "bob", 5, private)
+	ledger, other := account.NewLedger(), account.NewLedger()
+	if ledger.StateRoot() != (account.StateHash{}) {
+		t.Error("Unexpected state root of an empty ledger, want the zero hash")
+	}
+	ledger.SetBalance(sender, 42)
+	other.SetBalance(sender, 42)
+	ledger.SignedTransaction(first)
+	ledger.SignedTransaction(second)
+	other.SignedTransaction(second)
+	if ledger.StateRoot() == other.StateRoot() {
+		t.Error("Ledgers with different balances share a state root")
+	}
+	other.SignedTransaction(first)
+	if ledger.StateRoot() != other.StateRoot() {
+		t.Error("Ledgers with the same balances and nonces have different state roots")
+	}
+}
+
+func TestLedgersAgreeOnStateRootRegardlessOfRounding(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	first, _ := account.NewSignedTransaction("1", sender, "alice", 0.1, private)
+	second, _ := account.NewSignedTransaction("2", sender, "alice", 0.2, private)
+	ledger, other := account.NewLedger(), account.NewLedger()
+	ledger.SetBalance(sender, 1)
+	other.SetBalance(sender, 1)
+	ledger.SignedTransaction(first)
+	ledger.SignedTransaction(second)
+	other.SignedTransaction(second)
+	other.SignedTransaction(first)
+	if ledger.StateRoot() != other.StateRoot() {
+		t.Errorf("Unexpected state roots differing for balances %v and %v", ledger.GetBalance(sender), other.GetBalance(sender))
+	}
+}
+
+func TestLedgersProveTheStateOfAccounts(t *testing.T) {
+	ledger := account.NewLedger()
+	ledger.SetBalance("alice", 10)
+	ledger.SetBalance("bob", 20)
+	ledger.SetBalance("carol", 30)
+	root := ledger.StateRoot()
+	proof := ledger.Prove("bob")
+	if err := proof.Verify(root); err != nil || !proof.Exists || proof.State.Balance != 20 {
+		t.Errorf("Unexpected proof %v of bob (%v), want a valid proof of 20", proof.State, err)
+	}
+	proof.State.Balance = 2000
+	if err := proof.Verify(root); !errors.Is(err, account.ErrInvalidProof) {
+		t.Errorf("Unexpected error %v for a forged balance, want ErrInvalidProof", err)
+	}
+	absence := ledger.Prove("mallory")
+	if err := absence.Verify(root); err != nil || absence.Exists {
+		t.Errorf("Unexpected invalid proof of absence of mallory: %v", err)
+	}
+	absence.Account = "bob"
+	if err := absence.Verify(root); !errors.Is(err, account.ErrInvalidProof) {
+		t.Errorf("Unexpected error %v for a forged absence, want ErrInvalidProof", err)
+	}
+	ledger.SetBalance("carol", 31)
+	if err := ledger.Prove("bob").Verify(root); !errors.Is(err, account.ErrInvalidProof) {
+		t.Errorf("Unexpected error %v under an outdated root, want ErrInvalidProof", err)
+	}
+}
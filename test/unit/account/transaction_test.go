@@ -1,7 +1,9 @@
 package account_test
 
 import (
+	"errors"
 	"testing"
+	"time"
 	"vicoin/crypto"
 	"vicoin/internal/account"
 	"vicoin/internal/registration"
@@ -44,3 +46,91 @@ func TestIncorrectlySignedTransactionsCantBeValidated(t *testing.T) {
 		t.Error("Unable to validate correctly signed transaction")
 	}
 }
+
+func TestTransactionsAreOnlyValidWithinTheirWindow(t *testing.T) {
+	now := time.Now()
+	windows := []struct {
+		transaction account.SignedTransaction
+		want        error
+	}{
+		{account.SignedTransaction{}, nil},
+		{account.SignedTransaction{NotBefore: 5}, account.ErrNotYetValid},
+		{account.SignedTransaction{NotBefore: 3, ExpiresAt: 4}, nil},
+		{account.SignedTransaction{ExpiresAt: 3}, account.ErrExpired},
+		{account.SignedTransaction{NotBefore: now.Add(time.Hour).Unix()}, account.ErrNotYetValid},
+		{account.SignedTransaction{ExpiresAt: now.Add(-time.Hour).Unix()}, account.ErrExpired},
+		{account.SignedTransaction{NotBefore: now.Add(-time.Hour).Unix(), ExpiresAt: now.Add(time.Hour).Unix()}, nil},
+	}
+	for _, window := range windows {
+		if err := window.transaction.ValidAt(3, now); !errors.Is(err, window.want) {
+			t.Errorf("Unexpected error %v at height 3 for window %d to %d, want %v", err, window.transaction.NotBefore, window.transaction.ExpiresAt, window.want)
+		}
+	}
+}
+
+func TestLedgersApplyTransactionsWithinTheirWindowWhenAdmitted(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	ledger := account.NewLedger()
+	ledger.SetBalance(sender, 100)
+	expired, _ := account.SignTransaction(account.Transaction{ID: "1", From: sender, To: "recipient", Amount: 10, ExpiresAt: time.Now().Add(-time.Minute).Unix()}, private)
+	tampered := *expired
+	tampered.ExpiresAt = 0
+	if err := ledger.SignedTransaction(&tampered); err == nil {
+		t.Error("Expected an error for a validity window altered after signing")
+	}
+	early, _ := account.SignTransaction(account.Transaction{ID: "2", From: sender, To: "recipient", Amount: 10, NotBefore: 5}, private)
+	if err := ledger.SignedTransaction(expired); !errors.Is(err, account.ErrExpired) {
+		t.Errorf("Unexpected error %v for an expired transaction, want ErrExpired", err)
+	}
+	if err := ledger.SignedTransaction(early); !errors.Is(err, account.ErrNotYetValid) {
+		t.Errorf("Unexpected error %v for a transaction not valid yet, want ErrNotYetValid", err)
+	}
+	future := *early
+	future.Admitted = &account.Admission{Height: 5, Time: time.Now().Add(time.Hour).Unix()}
+	if err := ledger.SignedTransaction(&future); !errors.Is(err, account.ErrFutureAdmission) {
+		t.Errorf("Unexpected error %v for a transaction admitted in the future, want ErrFutureAdmission", err)
+	}
+	expired.Admitted = &account.Admission{Height: 0, Time: time.Now().Add(-time.Hour).Unix()}
+	early.Admitted = &account.Admission{Height: 5, Time: time.Now().Unix()}
+	for _, transaction := range []*account.SignedTransaction{expired, early} {
+		if err := ledger.SignedTransaction(transaction); err != nil {
+			t.Errorf("Error when applying transaction %s admitted by another ledger: %v", transaction.ID, err)
+		}
+	}
+	if history := ledger.History(0, 2); len(history) != 2 || *history[1].Admitted != *early.Admitted {
+		t.Errorf("Unexpected history %v, want it to keep the admissions", history)
+	}
+}
+
+func TestLedgersRecordTheAdmissionOfTransactions(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	ledger := account.NewLedger()
+	ledger.SetBalance(sender, 100)
+	transaction, _ := account.NewSignedTransaction("1", sender, "recipient", 10, private)
+	before := time.Now().Unix()
+	ledger.SignedTransaction(transaction)
+	history := ledger.History(0, 1)
+	if len(history) != 1 || history[0].Admitted == nil || history[0].Admitted.Height != 0 || history[0].Admitted.Time < before {
+		t.Errorf("Unexpected history %v, want the transaction admitted at height 0 and now", history)
+	}
+}
+
+func TestMempoolsDropExpiredTransactions(t *testing.T) {
+	mempool, _ := account.NewMempool(10)
+	mempool.Add(account.SignedTransaction{ID: "1", ExpiresAt: 2})
+	mempool.Add(account.SignedTransaction{ID: "2", NotBefore: 5})
+	mempool.Expire(2, time.Now())
+	if pending := mempool.Pending(); len(pending) != 1 || pending[0].ID != "2" {
+		t.Errorf("Unexpected pending transactions %v, want the one not valid yet", pending)
+	}
+	if ripe := mempool.Ripe(4, time.Now()); len(ripe) != 0 {
+		t.Errorf("Unexpected senders %v of transactions ripe before their height", ripe)
+	}
+	if ripe := mempool.Ripe(5, time.Now()); len(ripe) != 1 {
+		t.Errorf("Unexpected senders %v of transactions ripe at their height, want 1", ripe)
+	}
+}
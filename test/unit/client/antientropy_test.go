@@ -0,0 +1,84 @@
+package client_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"vicoin/crypto"
+	"vicoin/internal/account"
+	"vicoin/internal/client"
+	"vicoin/internal/registration"
+	"vicoin/network"
+	mocksNetwork "vicoin/test/mocks/network"
+	mocksNode "vicoin/test/mocks/node"
+)
+
+func TestClientsConvergeOnceReconciled(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	caller, callee, calleeAddr := mocksNetwork.NewRPCPair()
+	ledgers := []*account.Ledger{account.NewLedger(), account.NewLedger()}
+	clients := make([]*client.Client, 0)
+	for i, rpc := range []*network.RPC{caller, callee} {
+		ledgers[i].SetBalance(sender, 100)
+		node := mocksNode.NewMockNode()
+		node.Rpc = rpc
+		c, _ := client.NewClient(ledgers[i], node, make(chan account.SignedTransaction))
+		clients = append(clients, c)
+	}
+	// Split: each side applied transactions the other never received.
+	for i, id := range []string{"1", "2", "3"} {
+		transaction, _ := account.NewSignedTransaction(id, sender, "recipient", 10, private)
+		ledgers[i%2].SignedTransaction(transaction)
+	}
+	fetched, err := clients[0].Reconcile(context.Background(), calleeAddr)
+	if err != nil || fetched != 1 {
+		t.Errorf("Unexpected %d transactions fetched (%v), want 1", fetched, err)
+	}
+	fetched, err = clients[1].Reconcile(context.Background(), &net.TCPAddr{Port: 4242})
+	if err != nil || fetched != 2 {
+		t.Errorf("Unexpected %d transactions fetched (%v), want 2", fetched, err)
+	}
+	for i, ledger := range ledgers {
+		if ledger.Height() != 3 || ledger.GetBalance(sender) != 70 {
+			t.Errorf("Unexpected height %d and balance %f of ledger %d, want 3 and 70", ledger.Height(), ledger.GetBalance(sender), i)
+		}
+	}
+	if differences := ledgers[0].Summary().Differences(ledgers[1].Summary()); len(differences) != 0 {
+		t.Errorf("Unexpected differing buckets %v after reconciling", differences)
+	}
+	if fetched, _ := clients[0].Reconcile(context.Background(), calleeAddr); fetched != 0 {
+		t.Errorf("Unexpected %d transactions fetched once converged, want 0", fetched)
+	}
+}
+
+func TestClientsKeepReconciledTransactionsSpendingMissingFundsPending(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	alicePublic, alicePrivate, _ := crypto.KeyGen(2048)
+	alice, _ := alicePublic.ToString()
+	funding, _ := account.NewSignedTransaction("1", sender, alice, 50, private)
+	spending, _ := account.NewSignedTransaction("1", alice, "bob", 30, alicePrivate)
+	caller, callee, calleeAddr := mocksNetwork.NewRPCPair()
+	ledgers := []*account.Ledger{account.NewLedger(), account.NewLedger()}
+	clients := make([]*client.Client, 0)
+	for i, rpc := range []*network.RPC{caller, callee} {
+		ledgers[i].SetBalance(sender, 100)
+		node := mocksNode.NewMockNode()
+		node.Rpc = rpc
+		c, _ := client.NewClient(ledgers[i], node, make(chan account.SignedTransaction))
+		clients = append(clients, c)
+	}
+	ledgers[1].SetBalance(alice, 30)
+	ledgers[1].SignedTransaction(spending) // Ahead of its funding.
+	ledgers[1].SignedTransaction(funding)
+	fetched, err := clients[0].Reconcile(context.Background(), calleeAddr)
+	if err != nil || fetched != 2 {
+		t.Errorf("Unexpected %d transactions fetched (%v), want 2", fetched, err)
+	}
+	if ledgers[0].Height() != 2 || ledgers[0].GetBalance("bob") != 30 {
+		t.Errorf("Unexpected height %d and balance %f once reconciled, want 2 and 30", ledgers[0].Height(), ledgers[0].GetBalance("bob"))
+	}
+}
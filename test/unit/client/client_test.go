@@ -1,7 +1,9 @@
 package client_test
 
 import (
+	"errors"
 	"testing"
+	"time"
 	"vicoin/crypto"
 	"vicoin/internal/account"
 	"vicoin/internal/client"
@@ -45,7 +47,7 @@ func TestTransferAttemptsToPerformTransactionAndIncrementsNumberOfTransactions(t
 	internal := make(chan account.SignedTransaction)
 	c, err := client.NewClient(ledger, node, internal)
 	c.ProvideCredentials(public, private)
-	c.Transfer(10, "Santa")
+	c.Transfer(10, 0, "Santa")
 	if err != nil {
 		t.Error(err)
 	}
@@ -56,3 +58,116 @@ func TestTransferAttemptsToPerformTransactionAndIncrementsNumberOfTransactions(t
 		t.Error("Failed to increment transaction ID")
 	}
 }
+
+func TestClientsApplyPendingTransactionsByFeeRateOnceFunded(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	alicePublic, alicePrivate, _ := crypto.KeyGen(2048)
+	bobPublic, bobPrivate, _ := crypto.KeyGen(2048)
+	alice, _ := alicePublic.ToString()
+	bob, _ := bobPublic.ToString()
+	ledger := account.NewLedger()
+	ledger.SetBalance(bob, 100)
+	internal := make(chan account.SignedTransaction)
+	client.NewClient(ledger, mocksNode.NewMockNode(), internal)
+	cheap, _ := account.NewSignedTransactionWithFee("1", alice, "carol", 30, 1, alicePrivate)
+	generous, _ := account.NewSignedTransactionWithFee("2", alice, "carol", 30, 5, alicePrivate)
+	funding, _ := account.NewSignedTransaction("1", bob, alice, 40, bobPrivate)
+	internal <- *cheap
+	internal <- *generous
+	internal <- *funding
+	time.Sleep(50 * time.Millisecond)
+	if ledger.GetBalance(alice) != 5 || ledger.GetBalance("carol") != 30 {
+		t.Errorf("Unexpected balances %f and %f, want 5 and 30", ledger.GetBalance(alice), ledger.GetBalance("carol"))
+	}
+	if history := ledger.History(1, 10); len(history) != 1 || history[0].ID != "2" {
+		t.Errorf("Unexpected history %v, want the generous transaction only", history)
+	}
+}
+
+func TestClientsKeepTransactionsNotValidYetPending(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	ledger := account.NewLedger()
+	ledger.SetBalance(sender, 100)
+	internal := make(chan account.SignedTransaction)
+	client.NewClient(ledger, mocksNode.NewMockNode(), internal)
+	later, _ := account.SignTransaction(account.Transaction{ID: "2", From: sender, To: "recipient", Amount: 10, NotBefore: 1}, private)
+	first, _ := account.NewSignedTransaction("1", sender, "recipient", 10, private)
+	internal <- *later
+	internal <- *first
+	time.Sleep(50 * time.Millisecond)
+	if ledger.Height() != 2 || ledger.GetBalance("recipient") != 20 {
+		t.Errorf("Unexpected height %d and balance %f, want 2 and 20", ledger.Height(), ledger.GetBalance("recipient"))
+	}
+}
+
+func TestClientsTransferBatchesReportingEachOutput(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	ledger := account.NewLedger()
+	ledger.SetBalance(sender, 100)
+	node := mocksNode.NewMockNode()
+	c, _ := client.NewClient(ledger, node, make(chan account.SignedTransaction))
+	c.ProvideCredentials(public, private)
+	outputs := []account.Output{{To: "alice", Amount: 40}, {To: "bob", Amount: 40}}
+	results, err := c.TransferBatch(outputs, 0)
+	if err != nil || len(results) != 2 || results[1].Err != nil {
+		t.Errorf("Unexpected results %v (%v), want both outputs paid", results, err)
+	}
+	results, err = c.TransferBatch(outputs, 0)
+	if !errors.Is(err, account.ErrInsufficientFunds) || len(results) != 2 || !errors.Is(results[0].Err, account.ErrInsufficientFunds) {
+		t.Errorf("Unexpected results %v (%v), want the outputs to exceed the funds", results, err)
+	}
+	if ledger.GetBalance("alice") != 40 || ledger.GetBalance("bob") != 40 {
+		t.Errorf("Unexpected balances %f and %f, want 40 and 40", ledger.GetBalance("alice"), ledger.GetBalance("bob"))
+	}
+}
+
+func TestClientsSignAndSubmitMultisigTransactions(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	first, firstPrivate, _ := crypto.KeyGen(2048)
+	second, secondPrivate, _ := crypto.KeyGen(2048)
+	firstKey, _ := first.ToString()
+	secondKey, _ := second.ToString()
+	policy := account.MultisigPolicy{Threshold: 2, Keys: []string{firstKey, secondKey}}
+	ledger := account.NewLedger()
+	ledger.SetBalance(policy.Account(), 100)
+	c, _ := client.NewClient(ledger, mocksNode.NewMockNode(), make(chan account.SignedTransaction))
+	c.ProvideCredentials(first, firstPrivate)
+	transaction, _ := account.NewMultisigTransaction(account.Transaction{ID: "1", To: "alice", Amount: 30}, policy)
+	if err := c.Sign(transaction); err != nil {
+		t.Fatal("Error when signing: ", err)
+	}
+	if err := c.Submit(transaction); !errors.Is(err, account.ErrThresholdNotMet) {
+		t.Errorf("Unexpected error %v submitting 1 of 2 signatures, want ErrThresholdNotMet", err)
+	}
+	c.ProvideCredentials(second, secondPrivate)
+	c.Sign(transaction)
+	if err := c.Submit(transaction); err != nil {
+		t.Fatal("Error when submitting: ", err)
+	}
+	if ledger.GetBalance("alice") != 30 {
+		t.Errorf("Unexpected balance %f, want 30", ledger.GetBalance("alice"))
+	}
+}
+
+func TestClientsOnlyAdmitTransfersWithinTheirWindow(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	ledger := account.NewLedger()
+	ledger.SetBalance(sender, 100)
+	c, _ := client.NewClient(ledger, mocksNode.NewMockNode(), make(chan account.SignedTransaction))
+	c.ProvideCredentials(public, private)
+	if err := c.TransferWithin(10, 0, "recipient", 0, time.Now().Add(-time.Minute).Unix()); !errors.Is(err, account.ErrExpired) {
+		t.Errorf("Unexpected error %v for an expired transfer, want ErrExpired", err)
+	}
+	if err := c.TransferWithin(10, 0, "recipient", 5, 0); !errors.Is(err, account.ErrNotYetValid) {
+		t.Errorf("Unexpected error %v for a transfer not valid yet, want ErrNotYetValid", err)
+	}
+	if err := c.TransferWithin(10, 0, "recipient", 0, 5); err != nil || ledger.Height() != 1 {
+		t.Errorf("Unexpected error %v and height %d for a transfer within its window, want 1", err, ledger.Height())
+	}
+}
@@ -0,0 +1,78 @@
+package client_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"vicoin/crypto"
+	"vicoin/internal/account"
+	"vicoin/internal/client"
+	"vicoin/internal/registration"
+	mocksNetwork "vicoin/test/mocks/network"
+	mocksNode "vicoin/test/mocks/node"
+)
+
+// makeDivergingClients returns a client checking the ledger of a peer at the returned address, both funding sender.
+func makeDivergingClients(sender string) (*client.Client, *account.Ledger, *account.Ledger, net.Addr) {
+	caller, callee, addr := mocksNetwork.NewRPCPair()
+	local, remote := account.NewLedger(), account.NewLedger()
+	local.SetBalance(sender, 100)
+	remote.SetBalance(sender, 100)
+	remoteNode := mocksNode.NewMockNode()
+	remoteNode.Rpc = callee
+	client.NewClient(remote, remoteNode, make(chan account.SignedTransaction))
+	node := mocksNode.NewMockNode()
+	node.Rpc = caller
+	checking, _ := client.NewClient(local, node, make(chan account.SignedTransaction))
+	return checking, local, remote, addr
+}
+
+func TestClientsFindTheTransactionsTheirLedgersDivergedBy(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	checking, local, remote, addr := makeDivergingClients(sender)
+	transactions := make([]*account.SignedTransaction, 0)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		transaction, _ := account.NewSignedTransaction(id, sender, "recipient", 10, private)
+		transactions = append(transactions, transaction)
+	}
+	for i := range transactions { // In different orders, as honest ledgers may.
+		local.SignedTransaction(transactions[i])
+		remote.SignedTransaction(transactions[len(transactions)-1-i])
+	}
+	if report, err := checking.CheckDivergence(context.Background(), addr); report != nil || err != nil {
+		t.Errorf("Unexpected divergence %v (%v) of agreeing ledgers", report, err)
+	}
+	ours, _ := account.NewSignedTransaction("5", sender, "recipient", 10, private)
+	theirs, _ := account.NewSignedTransaction("5", sender, "recipient", 20, private)
+	local.SignedTransaction(ours)
+	remote.SignedTransaction(theirs)
+	report, err := checking.CheckDivergence(context.Background(), addr)
+	if err != nil || report == nil {
+		t.Fatalf("Unexpected missing divergence (%v)", err)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].Amount != 20 || len(report.Extra) != 1 || report.Extra[0].Amount != 10 {
+		t.Errorf("Unexpected missing %v and extra %v transactions, want theirs and ours", report.Missing, report.Extra)
+	}
+	path, err := report.Write(t.TempDir())
+	if err != nil {
+		t.Fatal("Error when writing report: ", err)
+	}
+	written, _ := os.ReadFile(path)
+	if !strings.Contains(string(written), "Missing 1 transactions") || !strings.Contains(string(written), "Extra 1 transactions") {
+		t.Errorf("Unexpected report %q", written)
+	}
+}
+
+func TestClientsReportLedgersStartingFromDifferentBalances(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	checking, local, _, addr := makeDivergingClients("sender")
+	local.SetBalance("alice", 1)
+	report, err := checking.CheckDivergence(context.Background(), addr)
+	if err != nil || report == nil || len(report.Missing) != 0 || len(report.Extra) != 0 {
+		t.Errorf("Unexpected report %v (%v), want no differing transactions", report, err)
+	}
+}
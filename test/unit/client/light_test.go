@@ -0,0 +1,71 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"vicoin/internal/account"
+	"vicoin/internal/client"
+	"vicoin/internal/registration"
+	"vicoin/network"
+	mocksNetwork "vicoin/test/mocks/network"
+	mocksNode "vicoin/test/mocks/node"
+)
+
+func makeLightClient(quorum int) (*client.LightClient, *network.RPC) {
+	caller, callee, addr := mocksNetwork.NewRPCPair()
+	node := mocksNode.NewMockNode()
+	node.Rpc = caller
+	node.SyncPeers = []net.Addr{addr}
+	return client.NewLightClient(node, make(chan account.SignedTransaction), quorum), callee
+}
+
+func TestLightClientsGetBalancesProvenByFullNodes(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	light, callee := makeLightClient(1)
+	ledger := account.NewLedger()
+	ledger.SetBalance("alice", 42)
+	fullNode := mocksNode.NewMockNode()
+	fullNode.Rpc = callee
+	client.NewClient(ledger, fullNode, make(chan account.SignedTransaction))
+	balance, err := light.GetBalance(context.Background(), "alice")
+	if err != nil || balance != 42 {
+		t.Errorf("Unexpected balance %f (%v), want 42", balance, err)
+	}
+	if light.Root() != ledger.StateRoot() {
+		t.Error("Unexpected state root, want that of the full node")
+	}
+	if balance, err := light.GetBalance(context.Background(), "bob"); err != nil || balance != 0 {
+		t.Errorf("Unexpected balance %f (%v) of an unknown account, want 0", balance, err)
+	}
+}
+
+func TestLightClientsRejectForgedProofs(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	light, callee := makeLightClient(1)
+	ledger := account.NewLedger()
+	ledger.SetBalance("alice", 42)
+	network.HandleTyped(callee, "state.root", func(ctx context.Context, from net.Addr, _ int) (account.StateHash, error) {
+		return ledger.StateRoot(), nil
+	})
+	network.HandleTyped(callee, "state.proof", func(ctx context.Context, from net.Addr, acc string) (account.StateProof, error) {
+		proof := ledger.Prove(acc)
+		proof.State.Balance = 1000000
+		return proof, nil
+	})
+	if _, err := light.GetBalance(context.Background(), "alice"); !errors.Is(err, client.ErrNoValidProof) {
+		t.Errorf("Unexpected error %v, want ErrNoValidProof", err)
+	}
+}
+
+func TestLightClientsRequireAQuorumOfPeers(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	light, callee := makeLightClient(2)
+	fullNode := mocksNode.NewMockNode()
+	fullNode.Rpc = callee
+	client.NewClient(account.NewLedger(), fullNode, make(chan account.SignedTransaction))
+	if _, err := light.GetBalance(context.Background(), "alice"); !errors.Is(err, client.ErrNoQuorum) {
+		t.Errorf("Unexpected error %v, want ErrNoQuorum", err)
+	}
+}
@@ -0,0 +1,129 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+	"vicoin/crypto"
+	"vicoin/internal/account"
+	"vicoin/internal/client"
+	"vicoin/internal/registration"
+	mocksAcc "vicoin/test/mocks/account"
+	mocksNetwork "vicoin/test/mocks/network"
+	mocksNode "vicoin/test/mocks/node"
+)
+
+func TestClientsSyncTheHistoryOfTheirPeers(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	caller, callee, addr := mocksNetwork.NewRPCPair()
+	remoteLedger := mocksAcc.NewMockLedger()
+	for i := 0; i < 1200; i++ {
+		remoteLedger.SignedTransaction(&account.SignedTransaction{ID: "id"})
+	}
+	remoteNode := mocksNode.NewMockNode()
+	remoteNode.Rpc = callee
+	client.NewClient(remoteLedger, remoteNode, make(chan account.SignedTransaction))
+	ledger := mocksAcc.NewMockLedger()
+	node := mocksNode.NewMockNode()
+	node.Rpc = caller
+	node.SyncPeers = []net.Addr{addr}
+	syncing, _ := client.NewClient(ledger, node, make(chan account.SignedTransaction))
+	reports := make([]client.SyncProgress, 0)
+	err := syncing.Sync(context.Background(), func(progress client.SyncProgress) {
+		reports = append(reports, progress)
+	})
+	if err != nil {
+		t.Fatal("Error when syncing: ", err)
+	}
+	if ledger.Height() != 1200 || !syncing.Synced() {
+		t.Errorf("Unexpected height %d after sync, want 1200", ledger.Height())
+	}
+	if len(reports) != 3 || reports[2].Downloaded != 1200 || reports[2].Target != 1200 {
+		t.Errorf("Unexpected progress reports %v", reports)
+	}
+}
+
+func TestClientsCantSyncWithoutPeers(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	ledger, node := makeDependencies()
+	syncing, _ := client.NewClient(ledger, node, make(chan account.SignedTransaction))
+	if err := syncing.Sync(context.Background(), nil); !errors.Is(err, client.ErrNoSyncPeers) {
+		t.Errorf("Unexpected error %v, want ErrNoSyncPeers", err)
+	}
+	if syncing.Synced() {
+		t.Error("Client reported synced without syncing")
+	}
+}
+
+func TestClientsKeepSyncedTransactionsSpendingMissingFundsPending(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	alicePublic, alicePrivate, _ := crypto.KeyGen(2048)
+	alice, _ := alicePublic.ToString()
+	funding, _ := account.NewSignedTransaction("1", sender, alice, 50, private)
+	spending, _ := account.NewSignedTransaction("1", alice, "bob", 30, alicePrivate)
+	overspending, _ := account.NewSignedTransaction("2", alice, "bob", 1000, alicePrivate)
+	caller, callee, addr := mocksNetwork.NewRPCPair()
+	remoteLedger := mocksAcc.NewMockLedger()
+	remoteLedger.SignedTransaction(spending) // Ahead of its funding.
+	remoteLedger.SignedTransaction(funding)
+	remoteNode := mocksNode.NewMockNode()
+	remoteNode.Rpc = callee
+	client.NewClient(remoteLedger, remoteNode, make(chan account.SignedTransaction))
+	ledger := account.NewLedger()
+	ledger.SetBalance(sender, 100)
+	node := mocksNode.NewMockNode()
+	node.Rpc = caller
+	node.SyncPeers = []net.Addr{addr}
+	syncing, _ := client.NewClient(ledger, node, make(chan account.SignedTransaction))
+	reports := make([]client.SyncProgress, 0)
+	err := syncing.Sync(context.Background(), func(progress client.SyncProgress) {
+		reports = append(reports, progress)
+	})
+	if err != nil || len(reports) != 1 || reports[0].Pending != 1 {
+		t.Fatalf("Unexpected progress reports %v (%v), want 1 transaction pending", reports, err)
+	}
+	if ledger.Height() != 2 || ledger.GetBalance("bob") != 30 {
+		t.Errorf("Unexpected height %d and balance %f once synced, want 2 and 30", ledger.Height(), ledger.GetBalance("bob"))
+	}
+	remoteLedger.SignedTransaction(overspending)
+	syncing, _ = client.NewClient(ledger, node, make(chan account.SignedTransaction))
+	if err := syncing.Sync(context.Background(), nil); !errors.Is(err, client.ErrSyncIncomplete) || syncing.Synced() {
+		t.Errorf("Unexpected error %v syncing a history overspending, want ErrSyncIncomplete", err)
+	}
+}
+
+func TestClientsSyncHistoryWithinTheWindowsItWasAdmitted(t *testing.T) {
+	registration.RegisterStructsWithGob()
+	public, private, _ := crypto.KeyGen(2048)
+	sender, _ := public.ToString()
+	expired, _ := account.SignTransaction(account.Transaction{ID: "1", From: sender, To: "recipient", Amount: 10, ExpiresAt: time.Now().Add(-time.Minute).Unix()}, private)
+	caller, callee, addr := mocksNetwork.NewRPCPair()
+	remoteLedger := mocksAcc.NewMockLedger()
+	remoteNode := mocksNode.NewMockNode()
+	remoteNode.Rpc = callee
+	client.NewClient(remoteLedger, remoteNode, make(chan account.SignedTransaction))
+	ledger := account.NewLedger()
+	ledger.SetBalance(sender, 100)
+	node := mocksNode.NewMockNode()
+	node.Rpc = caller
+	node.SyncPeers = []net.Addr{addr}
+	syncing, _ := client.NewClient(ledger, node, make(chan account.SignedTransaction))
+	unadmitted := *expired
+	remoteLedger.SignedTransaction(&unadmitted) // Claiming no admission, so checked now.
+	if err := syncing.Sync(context.Background(), nil); err == nil || ledger.GetBalance("recipient") != 0 {
+		t.Errorf("Unexpected error %v and balance %f syncing a transaction expired when admitted, want an error and 0", err, ledger.GetBalance("recipient"))
+	}
+	remoteLedger = mocksAcc.NewMockLedger()
+	expired.Admitted = &account.Admission{Height: 0, Time: time.Now().Add(-time.Hour).Unix()}
+	remoteLedger.SignedTransaction(expired) // Admitted by the peer before it expired.
+	remoteNode = mocksNode.NewMockNode()
+	remoteNode.Rpc = callee
+	client.NewClient(remoteLedger, remoteNode, make(chan account.SignedTransaction))
+	if err := syncing.Sync(context.Background(), nil); err != nil || ledger.GetBalance("recipient") != 10 {
+		t.Errorf("Unexpected error %v and balance %f syncing a transaction expired since, want 10", err, ledger.GetBalance("recipient"))
+	}
+}
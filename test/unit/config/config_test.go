@@ -0,0 +1,133 @@
+package config_test
+
+import (
+	"net"
+	"testing"
+	"vicoin/internal/account"
+	"vicoin/internal/config"
+)
+
+func TestConfigsDefaultToAnyFreePort(t *testing.T) {
+	cfg, err := config.Parse([]string{})
+	if err != nil {
+		t.Fatal("Error when parsing empty arguments: ", err)
+	}
+	if cfg.Listen != ":0" || len(cfg.Advertise) != 0 {
+		t.Errorf("Unexpected defaults %v", cfg)
+	}
+}
+
+func TestConfigsParseListenAndAdvertisedAddresses(t *testing.T) {
+	cfg, err := config.Parse([]string{"-listen", "127.0.0.1:4000", "-advertise", "203.0.113.7:4000, [2001:db8::7]:4000"})
+	if err != nil {
+		t.Fatal("Error when parsing arguments: ", err)
+	}
+	if cfg.Listen != "127.0.0.1:4000" {
+		t.Errorf("Unexpected listen address %s, want 127.0.0.1:4000", cfg.Listen)
+	}
+	addrs, err := cfg.AdvertisedAddrs(&net.TCPAddr{})
+	if err != nil {
+		t.Fatal("Error when resolving advertised addresses: ", err)
+	}
+	if len(addrs) != 2 || addrs[0].String() != "203.0.113.7:4000" || addrs[1].String() != "[2001:db8::7]:4000" {
+		t.Errorf("Unexpected advertised addresses %v", addrs)
+	}
+}
+
+func TestConfigsAdvertiseListeningAddressByDefault(t *testing.T) {
+	listening := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4000}
+	addrs, _ := config.Default().AdvertisedAddrs(listening)
+	if len(addrs) != 1 || addrs[0] != listening {
+		t.Errorf("Unexpected advertised addresses %v, want %s", addrs, listening)
+	}
+}
+
+func TestConfigsAdvertiseADialableAddressWhenListeningEverywhere(t *testing.T) {
+	addrs, err := config.Default().AdvertisedAddrs(&net.TCPAddr{IP: net.IPv6unspecified, Port: 4000})
+	if err != nil || len(addrs) != 1 {
+		t.Fatalf("Unexpected advertised addresses %v (%v), want 1", addrs, err)
+	}
+	if addr := addrs[0].(*net.TCPAddr); addr.IP == nil || addr.IP.IsUnspecified() || addr.Port != 4000 {
+		t.Errorf("Unexpected advertised address %s, want a specific IP and port 4000", addr)
+	}
+}
+
+func TestConfigsRejectUnspecifiedAdvertisedAddresses(t *testing.T) {
+	cfg, _ := config.Parse([]string{"-advertise", "0.0.0.0:4000"})
+	if _, err := cfg.AdvertisedAddrs(&net.TCPAddr{}); err == nil {
+		t.Error("Accepted unspecified advertised address")
+	}
+}
+
+func TestConfigsRejectInvalidListenAddresses(t *testing.T) {
+	if _, err := config.Parse([]string{"-listen", "nonsense"}); err == nil {
+		t.Error("Accepted invalid listen address")
+	}
+}
+
+func TestConfigsParseGossipSettings(t *testing.T) {
+	cfg, err := config.Parse([]string{"-fanout", "3", "-gossip-ttl", "5", "-lazy-push=false"})
+	if err != nil {
+		t.Fatal("Error when parsing arguments: ", err)
+	}
+	if cfg.Gossip.Fanout != 3 || cfg.Gossip.TTL != 5 || cfg.Gossip.LazyPush {
+		t.Errorf("Unexpected gossip settings %v", cfg.Gossip)
+	}
+	if _, err := config.Parse([]string{"-gossip-ttl", "0"}); err == nil {
+		t.Error("Expected an error for a zero gossip TTL")
+	}
+}
+
+func TestConfigsParseSeedsAndPeerBook(t *testing.T) {
+	cfg, err := config.Parse([]string{"-seeds", "127.0.0.1:4000, 127.0.0.2:4000", "-peerbook", ""})
+	if err != nil {
+		t.Fatal("Error when parsing arguments: ", err)
+	}
+	seeds, err := cfg.SeedAddrs()
+	if err != nil || len(seeds) != 2 || seeds[1].String() != "127.0.0.2:4000" {
+		t.Errorf("Unexpected seeds %v", seeds)
+	}
+	if cfg.PeerBook != "" {
+		t.Errorf("Unexpected peer book %q, want none", cfg.PeerBook)
+	}
+}
+
+func TestConfigsKeepTheNodeKeyByDefault(t *testing.T) {
+	cfg, _ := config.Parse([]string{})
+	if cfg.NodeKey == "" {
+		t.Error("Unexpected new node key each start by default")
+	}
+	cfg, _ = config.Parse([]string{"-nodekey", ""})
+	if cfg.NodeKey != "" {
+		t.Errorf("Unexpected node key file %q, want none", cfg.NodeKey)
+	}
+}
+
+func TestConfigsParseLightClientMode(t *testing.T) {
+	cfg, err := config.Parse([]string{"-light", "-quorum", "3"})
+	if err != nil {
+		t.Fatal("Error when parsing arguments: ", err)
+	}
+	if !cfg.Light || cfg.Quorum != 3 {
+		t.Errorf("Unexpected light mode %t with quorum %d, want true and 3", cfg.Light, cfg.Quorum)
+	}
+	if _, err := config.Parse([]string{"-quorum", "0"}); err == nil {
+		t.Error("Expected an error for a zero quorum")
+	}
+}
+
+func TestConfigsDefaultToTheDefaultGenesis(t *testing.T) {
+	cfg, _ := config.Parse([]string{})
+	genesis, err := cfg.LoadGenesis()
+	if err != nil || genesis.ChainID() != account.DefaultGenesis().ChainID() {
+		t.Errorf("Unexpected genesis %v (%v), want the default one", genesis, err)
+	}
+	genesis.Params.MinFeeRate = 1
+	if account.DefaultGenesis().Params.MinFeeRate != 0 {
+		t.Error("Changes to a loaded genesis leaked into the default one")
+	}
+	cfg, _ = config.Parse([]string{"-genesis", "missing.json"})
+	if _, err := cfg.LoadGenesis(); err == nil {
+		t.Error("Expected an error for a missing genesis file")
+	}
+}
@@ -32,3 +32,21 @@ func TestPrivateKeysCanBeBase64StringEncodedDecoded(t *testing.T) {
 		t.Error("Error keys are unequal", private, privateFromString)
 	}
 }
+
+func TestKeysDecodedFromOtherDataAreRefused(t *testing.T) {
+	gob.Register(crypto.PublicKey{})
+	gob.Register(crypto.PrivateKey{})
+	for _, str := range []string{"AAAA", "not base64", ""} {
+		if _, err := new(crypto.PublicKey).FromString(str); err == nil {
+			t.Errorf("Accepted public key %q", str)
+		}
+		if _, err := new(crypto.PrivateKey).FromString(str); err == nil {
+			t.Errorf("Accepted private key %q", str)
+		}
+	}
+	_, private, _ := crypto.KeyGen(128)
+	privateString, _ := private.ToString()
+	if _, err := new(crypto.PublicKey).FromString(privateString); err == nil {
+		t.Error("Accepted a private key as a public key")
+	}
+}
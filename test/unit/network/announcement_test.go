@@ -0,0 +1,31 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"vicoin/network"
+)
+
+func TestAnnouncementsVerifyWhenUnaltered(t *testing.T) {
+	key, _ := network.NewNodeKey()
+	announcement := network.NewAnnouncement(key, []net.Addr{&net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4000}})
+	if err := announcement.Verify(); err != nil {
+		t.Error("Error when verifying announcement: ", err)
+	}
+	if announcement.ID() != key.ID() {
+		t.Error("Announcement ID differs from the key ID")
+	}
+	announcement.Addrs[0] = "198.51.100.7:4000"
+	if announcement.Verify() == nil {
+		t.Error("Altered announcement was verified")
+	}
+}
+
+func TestAnnouncementsExpire(t *testing.T) {
+	key, _ := network.NewNodeKey()
+	announcement := network.NewAnnouncement(key, []net.Addr{&net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4000}})
+	announcement.Timestamp -= int64(network.MaxAnnouncementAge.Seconds()) + 1
+	if announcement.Verify() == nil {
+		t.Error("Expired announcement was verified")
+	}
+}
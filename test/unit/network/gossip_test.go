@@ -0,0 +1,160 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+	"vicoin/network"
+	mocks "vicoin/test/mocks/network"
+)
+
+// makeGossipSocket returns a mock socket with connections negotiating gossip on the given ports.
+func makeGossipSocket(ports ...int) (*mocks.MockPolysocket, []net.Addr) {
+	mock := &mocks.MockPolysocket{Conns: make(map[string]net.Conn)}
+	addrs := make([]net.Addr, 0, len(ports))
+	for _, port := range ports {
+		conn, _, _, _ := exchangeHellos(makeHello("chain"), makeHello("chain"))
+		addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: port}
+		mock.Conns[addr.String()] = mocks.WrapMockConn(conn, addr)
+		addrs = append(addrs, addr)
+	}
+	return mock, addrs
+}
+
+func countInstruction(messages []interface{}, instruction network.Insn) int {
+	count := 0
+	for _, msg := range messages {
+		if msg.(network.Packet).Instruction == instruction {
+			count++
+		}
+	}
+	return count
+}
+
+func TestGossipPushesToFanoutAndAnnouncesToRest(t *testing.T) {
+	mock, _ := makeGossipSocket(1, 2, 3, 4, 5)
+	gossip := network.NewGossip(mock, network.GossipConfig{Fanout: 2, TTL: 3, LazyPush: true})
+	gossip.Publish(network.Packet{Instruction: network.Transaction, Data: "lorem ipsum"})
+	if pushed := countInstruction(mock.SentMessages, network.Transaction); pushed != 2 {
+		t.Errorf("Unexpected number of pushes %d, want 2", pushed)
+	}
+	if announced := countInstruction(mock.SentMessages, network.Inv); announced != 3 {
+		t.Errorf("Unexpected number of announcements %d, want 3", announced)
+	}
+}
+
+func TestGossipWithoutLazyPushOnlyPushesToFanout(t *testing.T) {
+	mock, _ := makeGossipSocket(1, 2, 3, 4, 5)
+	gossip := network.NewGossip(mock, network.GossipConfig{Fanout: 2, TTL: 3, LazyPush: false})
+	gossip.Publish(network.Packet{Instruction: network.Transaction, Data: "lorem ipsum"})
+	if len(mock.SentMessages) != 2 {
+		t.Errorf("Unexpected number of sent messages %d, want 2", len(mock.SentMessages))
+	}
+}
+
+func TestGossipPublishesWithConfiguredTTL(t *testing.T) {
+	mock, _ := makeGossipSocket(1)
+	gossip := network.NewGossip(mock, network.GossipConfig{Fanout: 0, TTL: 3})
+	gossip.Publish(network.Packet{Instruction: network.Transaction, Data: "lorem ipsum"})
+	if ttl := mock.SentMessages[0].(network.Packet).TTL; ttl != 3 {
+		t.Errorf("Unexpected TTL %d, want 3", ttl)
+	}
+}
+
+func TestGossipReceivesMessagesOnce(t *testing.T) {
+	mock, addrs := makeGossipSocket(1, 2)
+	gossip := network.NewGossip(mock, network.GossipConfig{Fanout: 0, TTL: 3})
+	packet := network.Packet{Instruction: network.Transaction, Data: "lorem ipsum", TTL: 3}.WithOrigin(addrs[0])
+	if !gossip.Receive(packet) {
+		t.Error("New message reported as seen")
+	}
+	if gossip.Receive(packet) {
+		t.Error("Repeated message reported as new")
+	}
+	if len(mock.SentMessages) != 1 || mock.SentTo[0] != addrs[1] {
+		t.Errorf("Unexpected relays %v, want one to %s", mock.SentTo, addrs[1])
+	}
+}
+
+func TestGossipRequestsOnlyMissingMessages(t *testing.T) {
+	mock, addrs := makeGossipSocket(1)
+	gossip := network.NewGossip(mock, network.DefaultGossipConfig)
+	known := network.Packet{Instruction: network.Transaction, Data: "lorem ipsum"}
+	gossip.Publish(known)
+	mock.SentMessages = nil
+	knownID, _ := network.NewMessageID(known)
+	missingID, _ := network.NewMessageID(network.Packet{Instruction: network.Transaction, Data: "ipsum lorem"})
+	gossip.HandleInv(network.Packet{}.WithOrigin(addrs[0]), []network.MessageID{knownID, missingID})
+	request := mock.SentMessages[0].(network.Packet)
+	ids := request.Data.([]network.MessageID)
+	if request.Instruction != network.GetData || len(ids) != 1 || ids[0] != missingID {
+		t.Errorf("Unexpected request %v, want GetData for the missing message", request)
+	}
+}
+
+func TestGossipAnswersRequestsForStoredMessages(t *testing.T) {
+	mock, addrs := makeGossipSocket(1)
+	gossip := network.NewGossip(mock, network.DefaultGossipConfig)
+	stored := network.Packet{Instruction: network.Transaction, Data: "lorem ipsum"}
+	gossip.Publish(stored)
+	mock.SentMessages = nil
+	id, _ := network.NewMessageID(stored)
+	gossip.HandleGetData(network.Packet{}.WithOrigin(addrs[0]), []network.MessageID{id})
+	if len(mock.SentMessages) != 1 {
+		t.Fatalf("Unexpected number of answers %d, want 1", len(mock.SentMessages))
+	}
+	answer := mock.SentMessages[0].(network.Packet)
+	if answer.Instruction != network.Data || answer.Data.(network.Packet).Data != "lorem ipsum" {
+		t.Errorf("Unexpected answer %v, want the stored message as Data", answer)
+	}
+}
+
+func TestGossipRetriesRequestsWithOtherAnnouncers(t *testing.T) {
+	mock, addrs := makeGossipSocket(1, 2)
+	config := network.DefaultGossipConfig
+	config.RequestTimeout = 20 * time.Millisecond
+	gossip := network.NewGossip(mock, config)
+	id, _ := network.NewMessageID(network.Packet{Instruction: network.Transaction, Data: "lorem ipsum"})
+	gossip.HandleInv(network.Packet{}.WithOrigin(addrs[0]), []network.MessageID{id})
+	gossip.HandleInv(network.Packet{}.WithOrigin(addrs[1]), []network.MessageID{id})
+	if len(mock.SentMessages) != 1 || mock.SentTo[0] != addrs[0] {
+		t.Fatalf("Unexpected requests to %v, want one to %s", mock.SentTo, addrs[0])
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(mock.SentMessages) != 2 || mock.SentTo[1] != addrs[1] {
+		t.Errorf("Unexpected requests to %v, want a retry to %s", mock.SentTo, addrs[1])
+	}
+}
+
+func TestGossipAcceptsOnlyRequestedData(t *testing.T) {
+	mock, addrs := makeGossipSocket(1)
+	gossip := network.NewGossip(mock, network.DefaultGossipConfig)
+	message := network.Packet{Instruction: network.Transaction, Data: "lorem ipsum"}
+	data := network.Packet{Instruction: network.Data, Data: message}.WithOrigin(addrs[0])
+	if _, ok := gossip.HandleData(data, message); ok {
+		t.Error("Unrequested message was accepted")
+	}
+	id, _ := network.NewMessageID(message)
+	gossip.HandleInv(network.Packet{}.WithOrigin(addrs[0]), []network.MessageID{id})
+	received, ok := gossip.HandleData(data, message)
+	if !ok || received.Origin() != addrs[0] {
+		t.Error("Requested message wasn't accepted from its sender")
+	}
+	if _, ok := gossip.HandleData(data, message); ok {
+		t.Error("Requested message was accepted twice")
+	}
+}
+
+func TestGossipOnlySpreadsToPeersNegotiatingTheCapability(t *testing.T) {
+	mock, _ := makeGossipSocket(1)
+	limited := makeHello("chain")
+	limited.Capabilities = []string{network.CapabilityGossip}
+	conn, _, _, _ := exchangeHellos(makeHello("chain"), limited)
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 2}
+	mock.Conns[addr.String()] = mocks.WrapMockConn(conn, addr)
+	gossip := network.NewGossip(mock, network.GossipConfig{Fanout: 0, TTL: 3, LazyPush: true})
+	gossip.Publish(network.Packet{Instruction: network.Transaction, Data: "lorem ipsum"})
+	if len(mock.SentTo) != 1 || mock.SentTo[0].String() == addr.String() {
+		t.Errorf("Unexpected recipients %v, want only the peer negotiating transactions", mock.SentTo)
+	}
+}
@@ -0,0 +1,73 @@
+package network
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"vicoin/network"
+)
+
+func makeHello(chainID string) network.Hello {
+	key, _ := network.NewNodeKey()
+	return network.NewHello(key.ID(), &net.TCPAddr{}, chainID)
+}
+
+func exchangeHellos(initiatorHello network.Hello, responderHello network.Hello) (net.Conn, error, net.Conn, error) {
+	local, remote := net.Pipe()
+	responded := make(chan handshakeResult)
+	go func() {
+		conn, err := network.NewHelloUpgrader(responderHello, time.Second).Upgrade(remote, false)
+		responded <- handshakeResult{conn, err}
+	}()
+	initiator, err := network.NewHelloUpgrader(initiatorHello, time.Second).Upgrade(local, true)
+	result := <-responded
+	return initiator, err, result.conn, result.err
+}
+
+func TestCompatibleNodesNegotiateCapabilities(t *testing.T) {
+	initiatorHello := makeHello("chain")
+	responderHello := makeHello("chain")
+	responderHello.Capabilities = []string{network.CapabilityTransactions, "unknown/1"}
+	initiator, initiatorErr, responder, responderErr := exchangeHellos(initiatorHello, responderHello)
+	if initiatorErr != nil || responderErr != nil {
+		t.Fatal("Error when exchanging hellos: ", initiatorErr, responderErr)
+	}
+	hello, ok := network.RemoteHello(initiator)
+	if !ok || hello.RemoteHello().NodeID != responderHello.NodeID {
+		t.Error("Initiator doesn't know the hello of the responder")
+	}
+	if !network.Supports(initiator, network.CapabilityTransactions) || !network.Supports(responder, network.CapabilityTransactions) {
+		t.Error("Shared capability wasn't negotiated")
+	}
+	if network.Supports(initiator, network.CapabilityPeers) || network.Supports(responder, "unknown/1") {
+		t.Error("Capability supported by one side only was negotiated")
+	}
+}
+
+func TestNodesOnDifferentChainsAreDisconnectedWithReason(t *testing.T) {
+	_, initiatorErr, _, responderErr := exchangeHellos(makeHello("chain"), makeHello("other"))
+	if initiatorErr == nil || responderErr == nil {
+		t.Fatal("Nodes on different chains were connected")
+	}
+	if !strings.Contains(initiatorErr.Error(), "chain") {
+		t.Errorf("Unexpected reason %q, want chain mismatch", initiatorErr)
+	}
+}
+
+func TestNodesRejectOutdatedProtocolVersions(t *testing.T) {
+	outdated := makeHello("chain")
+	outdated.Version = network.MinProtocolVersion - 1
+	_, initiatorErr, _, responderErr := exchangeHellos(outdated, makeHello("chain"))
+	if initiatorErr == nil || responderErr == nil {
+		t.Error("Node with outdated protocol version was connected")
+	}
+}
+
+func TestNodesRejectThemselves(t *testing.T) {
+	hello := makeHello("chain")
+	_, initiatorErr, _, responderErr := exchangeHellos(hello, hello)
+	if initiatorErr == nil || responderErr == nil {
+		t.Error("Node was connected to itself")
+	}
+}
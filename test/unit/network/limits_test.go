@@ -0,0 +1,51 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+	"vicoin/network"
+)
+
+func makeStats(ip string, age time.Duration, latency time.Duration) network.PeerStats {
+	return network.PeerStats{
+		Addr:      &net.TCPAddr{IP: net.ParseIP(ip), Port: 4000},
+		Connected: time.Now().Add(-age),
+		Latency:   latency,
+	}
+}
+
+func TestSubnetsGroupNeighbouringAddresses(t *testing.T) {
+	if network.Subnet(&net.TCPAddr{IP: net.ParseIP("10.0.0.1")}) != network.Subnet(&net.TCPAddr{IP: net.ParseIP("10.0.0.200")}) {
+		t.Error("Addresses in the same /24 are in different subnets")
+	}
+	if network.Subnet(&net.TCPAddr{IP: net.ParseIP("2001:db8::1")}) == network.Subnet(&net.TCPAddr{IP: net.ParseIP("2001:db8:0:1::1")}) {
+		t.Error("Addresses in different /64 are in the same subnet")
+	}
+}
+
+func TestEvictionsPickTheYoungestOfTheMostCrowdedSubnet(t *testing.T) {
+	candidates := []network.PeerStats{
+		makeStats("10.0.0.1", time.Hour, 0),
+		makeStats("10.0.0.2", time.Minute, 0),
+		makeStats("10.0.1.1", time.Second, 0),
+	}
+	evicted, ok := network.ProtectingEviction{Protect: 0}.Evict(candidates)
+	if !ok || evicted.String() != "10.0.0.2:4000" {
+		t.Errorf("Unexpected eviction %v, want 10.0.0.2:4000", evicted)
+	}
+}
+
+func TestEvictionsProtectLowLatencyAndUsefulPeers(t *testing.T) {
+	fast := makeStats("10.0.0.1", time.Second, time.Millisecond)
+	useful := makeStats("10.0.0.2", time.Second, 0)
+	useful.LastUseful = time.Now()
+	candidates := []network.PeerStats{fast, useful, makeStats("10.0.0.3", time.Hour, time.Second)}
+	evicted, ok := network.ProtectingEviction{Protect: 1}.Evict(candidates)
+	if !ok || evicted.String() != "10.0.0.3:4000" {
+		t.Errorf("Unexpected eviction %v, want 10.0.0.3:4000", evicted)
+	}
+	if _, ok := (network.ProtectingEviction{Protect: 2}).Evict(candidates[:2]); ok {
+		t.Error("Protected peer was evicted")
+	}
+}
@@ -0,0 +1,104 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+	"vicoin/network"
+)
+
+// flakyDialer hands out its net.Conns, failing once it runs out.
+type flakyDialer struct {
+	conns []net.Conn
+}
+
+func (dialer *flakyDialer) Dial(net.Addr) (net.Conn, error) {
+	if len(dialer.conns) == 0 {
+		return nil, errors.New("unreachable")
+	}
+	conn := dialer.conns[0]
+	dialer.conns = dialer.conns[1:]
+	return conn, nil
+}
+
+var fastPolicy = network.BackoffPolicy{
+	Initial:     time.Millisecond,
+	Max:         5 * time.Millisecond,
+	Multiplier:  2,
+	Jitter:      0,
+	MaxAttempts: 3,
+}
+
+func TestBackoffDelaysGrowExponentiallyUpToMax(t *testing.T) {
+	policy := network.BackoffPolicy{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2, Jitter: 0}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if delay := policy.Delay(i + 1); delay != want {
+			t.Errorf("Unexpected delay %s for attempt %d, want %s", delay, i+1, want)
+		}
+	}
+}
+
+func TestBackoffDelaysAreJitteredWithinBounds(t *testing.T) {
+	policy := network.BackoffPolicy{Initial: time.Second, Max: time.Second, Multiplier: 2, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if delay := policy.Delay(1); delay < 500*time.Millisecond || delay > 1500*time.Millisecond {
+			t.Fatalf("Jittered delay %s out of bounds", delay)
+		}
+	}
+}
+
+func TestConnectionManagersRedialDroppedPeers(t *testing.T) {
+	channel, dialer, listener := makeMockDependencies()
+	events := make(chan network.ConnectionEvent)
+	manager := network.NewConnectionManager(network.NewPolysocket(channel, dialer, listener), fastPolicy, events)
+	local, remote := net.Pipe()
+	dialer.SetNextSocket(local)
+	manager.Connect(&net.TCPAddr{})
+	reconnected, _ := net.Pipe()
+	dialer.SetNextSocket(reconnected)
+	remote.Close()
+	event := <-events
+	if event.Kind != network.Reconnected {
+		t.Errorf("Unexpected event %d, want Reconnected", event.Kind)
+	}
+	if len(manager.GetConnections()) != 1 {
+		t.Errorf("Unexpected # of connections %d, want 1", len(manager.GetConnections()))
+	}
+}
+
+func TestConnectionManagersGiveUpAfterMaxAttempts(t *testing.T) {
+	channel, _, listener := makeMockDependencies()
+	local, remote := net.Pipe()
+	dialer := &flakyDialer{conns: []net.Conn{local}}
+	events := make(chan network.ConnectionEvent)
+	manager := network.NewConnectionManager(network.NewPolysocket(channel, dialer, listener), fastPolicy, events)
+	manager.Connect(&net.TCPAddr{})
+	remote.Close()
+	event := <-events
+	if event.Kind != network.GaveUp {
+		t.Errorf("Unexpected event %d, want GaveUp", event.Kind)
+	}
+	if event.Attempts != fastPolicy.MaxAttempts || event.Err == nil {
+		t.Errorf("Unexpected # of attempts %d, want %d", event.Attempts, fastPolicy.MaxAttempts)
+	}
+}
+
+func TestConnectionManagersDontRedialForgottenPeers(t *testing.T) {
+	channel, dialer, listener := makeMockDependencies()
+	events := make(chan network.ConnectionEvent)
+	manager := network.NewConnectionManager(network.NewPolysocket(channel, dialer, listener), fastPolicy, events)
+	local, remote := net.Pipe()
+	dialer.SetNextSocket(local)
+	manager.Connect(&net.TCPAddr{})
+	manager.Forget(&net.TCPAddr{})
+	reconnected, _ := net.Pipe()
+	dialer.SetNextSocket(reconnected)
+	remote.Close()
+	select {
+	case event := <-events:
+		t.Errorf("Unexpected event %d for forgotten peer", event.Kind)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
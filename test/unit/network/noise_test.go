@@ -0,0 +1,99 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"vicoin/network"
+)
+
+type handshakeResult struct {
+	conn net.Conn
+	err  error
+}
+
+func handshake(t *testing.T) (net.Conn, net.Conn, *network.NodeKey, *network.NodeKey) {
+	initiatorKey, _ := network.NewNodeKey()
+	responderKey, _ := network.NewNodeKey()
+	local, remote := net.Pipe()
+	responded := make(chan handshakeResult)
+	go func() {
+		conn, err := network.NewNoiseUpgrader(responderKey, time.Second).Upgrade(remote, false)
+		responded <- handshakeResult{conn, err}
+	}()
+	initiator, err := network.NewNoiseUpgrader(initiatorKey, time.Second).Upgrade(local, true)
+	if err != nil {
+		t.Fatal("Error when initiating handshake: ", err)
+	}
+	result := <-responded
+	if result.err != nil {
+		t.Fatal("Error when responding to handshake: ", result.err)
+	}
+	return initiator, result.conn, initiatorKey, responderKey
+}
+
+func TestNoiseHandshakesRevealVerifiedRemoteIdentities(t *testing.T) {
+	initiator, responder, initiatorKey, responderKey := handshake(t)
+	identity, ok := network.RemoteIdentity(initiator)
+	if !ok || !bytes.Equal(identity, responderKey.Identity()) {
+		t.Error("Initiator doesn't know the identity of the responder")
+	}
+	identity, ok = network.RemoteIdentity(responder)
+	if !ok || !bytes.Equal(identity, initiatorKey.Identity()) {
+		t.Error("Responder doesn't know the identity of the initiator")
+	}
+}
+
+func TestSecureConnsDeliverWrittenData(t *testing.T) {
+	initiator, responder, _, _ := handshake(t)
+	sent := bytes.Repeat([]byte("lorem ipsum "), 10000) // Spans several frames.
+	go initiator.Write(sent)
+	received := make([]byte, len(sent))
+	_, err := io.ReadFull(responder, received)
+	if err != nil {
+		t.Error("Error when reading: ", err)
+	}
+	if !bytes.Equal(sent, received) {
+		t.Error("Received data doesn't equal sent data")
+	}
+}
+
+func TestNoiseHandshakesFailOnMalformedMessages(t *testing.T) {
+	key, _ := network.NewNodeKey()
+	local, remote := net.Pipe()
+	go remote.Write([]byte{0, 3, 1, 2, 3})
+	_, err := network.NewNoiseUpgrader(key, time.Second).Upgrade(local, false)
+	if err == nil {
+		t.Error("Accepted malformed handshake message")
+	}
+}
+
+func TestRemoteIdentityIsUnknownForPlainConns(t *testing.T) {
+	local, _ := net.Pipe()
+	if _, ok := network.RemoteIdentity(local); ok {
+		t.Error("Plain net.Conn reported a remote identity")
+	}
+}
+
+func TestNodeKeysAreKeptAcrossLoads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.key")
+	first, err := network.LoadNodeKey(path)
+	if err != nil {
+		t.Fatal("Error when generating node key: ", err)
+	}
+	second, err := network.LoadNodeKey(path)
+	if err != nil {
+		t.Fatal("Error when loading node key: ", err)
+	}
+	if first.ID() != second.ID() {
+		t.Errorf("Unexpected ID %s after reloading, want %s", second.ID(), first.ID())
+	}
+	os.WriteFile(path, []byte("not a key"), 0600)
+	if _, err := network.LoadNodeKey(path); err == nil {
+		t.Error("Unexpected success loading an invalid node key")
+	}
+}
@@ -2,7 +2,9 @@ package network
 
 import (
 	"encoding/gob"
+	"errors"
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 	"vicoin/network"
@@ -82,3 +84,53 @@ func TestPolysocketsDeliverReceivedMessagesOnChannel(t *testing.T) {
 		t.Error("Receive message doesn't equal sent message")
 	}
 }
+
+type countingUpgrader struct {
+	upgrades int32
+}
+
+func (upgrader *countingUpgrader) Upgrade(conn net.Conn, initiator bool) (net.Conn, error) {
+	atomic.AddInt32(&upgrader.upgrades, 1)
+	return conn, nil
+}
+
+type refusingEviction struct{}
+
+func (refusingEviction) Evict(candidates []network.PeerStats) (net.Addr, bool) {
+	return nil, false
+}
+
+func TestPolysocketsRefuseConnectionsBeyondTheirLimitsBeforeUpgrading(t *testing.T) {
+	channel, dialer, listener := makeMockDependencies()
+	upgrader := &countingUpgrader{}
+	poly := network.NewPolysocket(channel, dialer, network.NewUpgradedListener(listener, upgrader))
+	poly.SetLimits(network.ConnectionLimits{MaxInbound: 1})
+	poly.SetEvictionStrategy(refusingEviction{})
+	listener.SetNextSocket(mocks.NewMockConn(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}))
+	time.Sleep(5 * time.Millisecond)
+	listener.SetNextSocket(mocks.NewMockConn(&net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4000}))
+	time.Sleep(5 * time.Millisecond)
+	if len(poly.GetConnections()) != 1 {
+		t.Errorf("Unexpected # of connections %d, want 1", len(poly.GetConnections()))
+	}
+	if upgrades := atomic.LoadInt32(&upgrader.upgrades); upgrades != 1 {
+		t.Errorf("Unexpected # of upgrades %d, want 1", upgrades)
+	}
+}
+
+func TestPolysocketsReserveOutboundSlotsWhileDialing(t *testing.T) {
+	local, _ := net.Pipe()
+	channel, dialer, listener := makeMockDependencies()
+	poly := network.NewPolysocket(channel, dialer, listener)
+	poly.SetLimits(network.ConnectionLimits{MaxOutbound: 1})
+	go poly.Connect(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000})
+	time.Sleep(5 * time.Millisecond)
+	if _, err := poly.Connect(&net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4000}); !errors.Is(err, network.ErrConnectionLimit) {
+		t.Errorf("Unexpected error %v while another dial is in progress, want ErrConnectionLimit", err)
+	}
+	dialer.SetNextSocket(local)
+	time.Sleep(5 * time.Millisecond)
+	if len(poly.GetConnections()) != 1 {
+		t.Errorf("Unexpected # of connections %d, want 1", len(poly.GetConnections()))
+	}
+}
@@ -0,0 +1,49 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+	"vicoin/network"
+)
+
+func TestTokenBucketsAllowBurstsThenRefill(t *testing.T) {
+	bucket := network.NewTokenBucket(network.Rate{PerSecond: 100, Burst: 2})
+	if !bucket.Allow() || !bucket.Allow() {
+		t.Error("Burst wasn't allowed")
+	}
+	if bucket.Allow() {
+		t.Error("Event beyond the burst was allowed")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !bucket.Allow() {
+		t.Error("Bucket wasn't refilled")
+	}
+}
+
+func TestRateLimitersLimitPeersSeparately(t *testing.T) {
+	limiter := network.NewRateLimiter(network.Rate{PerSecond: 0, Burst: 3}, map[network.Insn]network.Rate{
+		network.Ping: {PerSecond: 0, Burst: 1},
+	})
+	first := &net.TCPAddr{Port: 4242}
+	second := &net.TCPAddr{Port: 4343}
+	if !limiter.Allow(first, network.Ping) || limiter.Allow(first, network.Ping) {
+		t.Error("Instruction wasn't limited to its burst")
+	}
+	if !limiter.Allow(first, network.Transaction) || !limiter.Allow(first, network.Transaction) || limiter.Allow(first, network.Transaction) {
+		t.Error("Peer wasn't limited to its total burst")
+	}
+	if !limiter.Allow(second, network.Ping) {
+		t.Error("Limit of one peer applied to another")
+	}
+}
+
+func TestRateLimitersForgetDroppedPeers(t *testing.T) {
+	limiter := network.NewRateLimiter(network.Rate{PerSecond: 0, Burst: 1}, map[network.Insn]network.Rate{})
+	addr := &net.TCPAddr{Port: 4242}
+	limiter.Allow(addr, network.Ping)
+	limiter.Forget(addr)
+	if !limiter.Allow(addr, network.Ping) {
+		t.Error("Buckets of a forgotten peer were kept")
+	}
+}
@@ -0,0 +1,55 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"vicoin/network"
+)
+
+func makeRegistry() *network.Registry {
+	registry := network.NewRegistry()
+	registry.Register(network.PeerRequest, "", func(data interface{}) error {
+		if data.(string) == "" {
+			return errors.New("empty")
+		}
+		return nil
+	})
+	return registry
+}
+
+func TestRegistriesDecodeValidPayloads(t *testing.T) {
+	data, err := makeRegistry().Decode(network.Packet{Instruction: network.PeerRequest, Data: "lorem ipsum"})
+	if err != nil {
+		t.Fatal("Error when decoding valid packet: ", err)
+	}
+	if data.(string) != "lorem ipsum" {
+		t.Errorf("Unexpected payload %v, want lorem ipsum", data)
+	}
+}
+
+func TestRegistriesRefusePayloadsOfWrongType(t *testing.T) {
+	_, err := makeRegistry().Decode(network.Packet{Instruction: network.PeerRequest, Data: 42})
+	var decodeErr *network.DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Instruction != network.PeerRequest {
+		t.Errorf("Unexpected error %v, want DecodeError", err)
+	}
+}
+
+func TestRegistriesRefuseMissingPayloads(t *testing.T) {
+	if _, err := makeRegistry().Decode(network.Packet{Instruction: network.PeerRequest}); err == nil {
+		t.Error("Decoded packet without payload")
+	}
+}
+
+func TestRegistriesRefuseInvalidPayloads(t *testing.T) {
+	if _, err := makeRegistry().Decode(network.Packet{Instruction: network.PeerRequest, Data: ""}); err == nil {
+		t.Error("Decoded packet failing validation")
+	}
+}
+
+func TestRegistriesRefuseUnknownInstructions(t *testing.T) {
+	_, err := makeRegistry().Decode(network.Packet{Instruction: network.Transaction, Data: "lorem ipsum"})
+	if !errors.Is(err, network.ErrUnknownInstruction) {
+		t.Errorf("Unexpected error %v, want ErrUnknownInstruction", err)
+	}
+}
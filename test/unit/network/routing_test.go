@@ -0,0 +1,59 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"vicoin/network"
+)
+
+func makeContact(id network.NodeID, port int) network.Contact {
+	return network.Contact{ID: id, Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: port}}
+}
+
+func TestDistancesAreSymmetricXOR(t *testing.T) {
+	first := network.NodeID{0x0f}
+	second := network.NodeID{0xf0}
+	if network.Distance(first, second) != (network.NodeID{0xff}) || network.Distance(first, second) != network.Distance(second, first) {
+		t.Errorf("Unexpected distance %s", network.Distance(first, second))
+	}
+	if !network.Closer(first, network.NodeID{0x0e}, second) {
+		t.Error("Nearer ID wasn't reported closer")
+	}
+}
+
+func TestRoutingTablesReturnClosestContactsFirst(t *testing.T) {
+	table := network.NewRoutingTable(network.NodeID{})
+	table.Add(makeContact(network.NodeID{0x80}, 1))
+	table.Add(makeContact(network.NodeID{0x01}, 2))
+	table.Add(makeContact(network.NodeID{0x10}, 3))
+	closest := table.Closest(network.NodeID{0x11}, 2)
+	if len(closest) != 2 || closest[0].ID != (network.NodeID{0x10}) || closest[1].ID != (network.NodeID{0x01}) {
+		t.Errorf("Unexpected closest contacts %v", closest)
+	}
+}
+
+func TestRoutingTablesKeepLongLivedContactsInFullBuckets(t *testing.T) {
+	table := network.NewRoutingTable(network.NodeID{})
+	for i := 0; i < network.BucketSize; i++ {
+		if !table.Add(makeContact(network.NodeID{0x80, byte(i)}, i+1)) {
+			t.Fatalf("Contact %d wasn't added to a bucket with room", i)
+		}
+	}
+	if table.Add(makeContact(network.NodeID{0x80, 0xff}, 4242)) {
+		t.Error("Contact was added to a full bucket")
+	}
+	if !table.Add(makeContact(network.NodeID{0x80, 0x00}, 1)) || table.Len() != network.BucketSize {
+		t.Error("Known contact wasn't refreshed")
+	}
+	if !table.Add(makeContact(network.NodeID{0x40}, 4242)) {
+		t.Error("Contact wasn't added to another bucket")
+	}
+}
+
+func TestRoutingTablesIgnoreThemselves(t *testing.T) {
+	self := network.NodeID{0x42}
+	table := network.NewRoutingTable(self)
+	if table.Add(makeContact(self, 1)) || table.Len() != 0 {
+		t.Error("Own ID was added to the routing table")
+	}
+}
@@ -0,0 +1,59 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+	"vicoin/network"
+	mocks "vicoin/test/mocks/network"
+)
+
+func TestRPCsAnswerTypedCalls(t *testing.T) {
+	caller, callee, addr := mocks.NewRPCPair()
+	network.HandleTyped(callee, "double", func(ctx context.Context, from net.Addr, request int) (int, error) {
+		return 2 * request, nil
+	})
+	doubled, err := network.CallTyped[int](context.Background(), caller, addr, "double", 21)
+	if err != nil || doubled != 42 {
+		t.Errorf("Unexpected answer %d (%v), want 42", doubled, err)
+	}
+	if _, err := caller.Call(context.Background(), addr, "double", "twenty-one"); err == nil {
+		t.Error("Request of the wrong type was answered")
+	}
+}
+
+func TestRPCsReportRemoteErrors(t *testing.T) {
+	caller, _, addr := mocks.NewRPCPair()
+	_, err := caller.Call(context.Background(), addr, "missing", nil)
+	var remote *network.RemoteError
+	if !errors.As(err, &remote) || remote.Method != "missing" {
+		t.Errorf("Unexpected error %v, want a RemoteError", err)
+	}
+}
+
+func TestRPCsGiveUpOnceTheContextIsDone(t *testing.T) {
+	socket := &mocks.RPCSocket{Addr: &net.TCPAddr{Port: 4242}, Silent: true}
+	caller := network.NewRPC(socket)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := caller.Call(ctx, &net.TCPAddr{Port: 4343}, "slow", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Unexpected error %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestRPCsIgnoreResponsesFromOtherPeers(t *testing.T) {
+	socket := &mocks.RPCSocket{Addr: &net.TCPAddr{Port: 4242}, Silent: true}
+	caller := network.NewRPC(socket)
+	go caller.Call(context.Background(), &net.TCPAddr{Port: 4343}, "method", nil)
+	time.Sleep(10 * time.Millisecond)
+	response := network.RPCResponse{ID: 1, Body: "forged"}
+	if caller.HandleResponse(network.Packet{}.WithOrigin(&net.TCPAddr{Port: 4444}), response) {
+		t.Error("Response from another peer was accepted")
+	}
+	if !caller.HandleResponse(network.Packet{}.WithOrigin(&net.TCPAddr{Port: 4343}), response) {
+		t.Error("Response from the called peer wasn't accepted")
+	}
+}
@@ -0,0 +1,57 @@
+package network
+
+import (
+	"testing"
+	"time"
+	"vicoin/network"
+)
+
+func makeID(b byte) network.MessageID {
+	return network.MessageID{b}
+}
+
+func TestSeenCachesReportRepeatedMessages(t *testing.T) {
+	cache := network.NewSeenCache(10, time.Minute)
+	if cache.Observe(makeID(1)) {
+		t.Error("New message reported as seen")
+	}
+	if !cache.Observe(makeID(1)) {
+		t.Error("Repeated message reported as unseen")
+	}
+}
+
+func TestSeenCachesEvictLeastRecentlySeen(t *testing.T) {
+	cache := network.NewSeenCache(2, time.Minute)
+	cache.Observe(makeID(1))
+	cache.Observe(makeID(2))
+	cache.Observe(makeID(1))
+	cache.Observe(makeID(3))
+	if cache.Len() != 2 {
+		t.Errorf("Unexpected length %d, want 2", cache.Len())
+	}
+	if cache.Contains(makeID(2)) || !cache.Contains(makeID(1)) || !cache.Contains(makeID(3)) {
+		t.Error("Evicted the wrong message")
+	}
+}
+
+func TestSeenCachesForgetExpiredMessages(t *testing.T) {
+	cache := network.NewSeenCache(10, 10*time.Millisecond)
+	cache.Observe(makeID(1))
+	time.Sleep(20 * time.Millisecond)
+	if cache.Contains(makeID(1)) {
+		t.Error("Expired message reported as seen")
+	}
+}
+
+func TestMessageIDsDependOnContent(t *testing.T) {
+	first, _ := network.NewMessageID(network.Packet{Instruction: network.Transaction, Data: "lorem"})
+	second, _ := network.NewMessageID(network.Packet{Instruction: network.Transaction, Data: "lorem"})
+	third, _ := network.NewMessageID(network.Packet{Instruction: network.Transaction, Data: "ipsum"})
+	fourth, _ := network.NewMessageID(network.Packet{Instruction: network.ConnAnnouncment, Data: "lorem"})
+	if first != second {
+		t.Error("Equal packets have different IDs")
+	}
+	if first == third || first == fourth {
+		t.Error("Different packets have equal IDs")
+	}
+}
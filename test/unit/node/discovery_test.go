@@ -0,0 +1,106 @@
+package node_test
+
+import (
+	"net"
+	"testing"
+	"time"
+	"vicoin/internal/account"
+	"vicoin/internal/node"
+	"vicoin/network"
+	mocks "vicoin/test/mocks/network"
+)
+
+// makeDiscoveryConn returns a net.Conn to a node listening at listenAddr, along with its ID.
+func makeDiscoveryConn(listenAddr *net.TCPAddr) (net.Conn, network.NodeID) {
+	local, remote := net.Pipe()
+	localKey, _ := network.NewNodeKey()
+	remoteKey, _ := network.NewNodeKey()
+	go network.NewHelloUpgrader(network.NewHello(remoteKey.ID(), listenAddr, "chain"), time.Second).Upgrade(remote, false)
+	conn, _ := network.NewHelloUpgrader(network.NewHello(localKey.ID(), &net.TCPAddr{}, "chain"), time.Second).Upgrade(local, true)
+	return mocks.WrapMockConn(conn, listenAddr), remoteKey.ID()
+}
+
+func TestNodesAnswerFindNodeWithNeighbours(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	node.NewNode(mock, internal, external)
+	origin := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}
+	conn, id := makeDiscoveryConn(origin)
+	mock.Conns = map[string]net.Conn{origin.String(): conn}
+	mock.InjectMessage(network.Packet{Instruction: network.FindNode, Data: network.NodeID{}}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	replies := sentWith(mock, network.Neighbours)
+	if len(replies) != 1 {
+		t.Fatalf("Unexpected number of replies %d, want 1", len(replies))
+	}
+	contacts := replies[0].Data.([]network.Contact)
+	if len(contacts) != 1 || contacts[0].ID != id {
+		t.Errorf("Unexpected neighbours %v, want the requester", contacts)
+	}
+}
+
+func TestNodesLookupContactsIteratively(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	first := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}
+	conn, _ := makeDiscoveryConn(first)
+	mock.Conns = map[string]net.Conn{first.String(): conn}
+	mock.InjectMessage(network.Packet{Instruction: network.FindNode, Data: network.NodeID{}}.WithOrigin(first))
+	time.Sleep(50 * time.Millisecond)
+	target := network.NodeID{0x42}
+	found := make(chan []network.Contact)
+	go func() {
+		found <- n.Lookup(target)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if requests := sentWith(mock, network.FindNode); len(requests) != 1 {
+		t.Fatalf("Unexpected number of requests %d, want 1", len(requests))
+	}
+	second := network.Contact{ID: target, Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4000}}
+	mock.InjectMessage(network.Packet{Instruction: network.Neighbours, Data: []network.Contact{second}}.WithOrigin(first))
+	contacts := <-found
+	if len(contacts) != 2 || contacts[0].ID != second.ID {
+		t.Errorf("Unexpected contacts %v, want the announced one first", contacts)
+	}
+	if len(mock.Connections) != 1 || mock.Connections[0] != second.Addr {
+		t.Errorf("Unexpected dials %v, want one to %s", mock.Connections, second.Addr)
+	}
+	if len(n.GetPeers()) != 2 { // Only the contact which answered is promoted.
+		t.Errorf("Unexpected number of peers %d, want 2", len(n.GetPeers()))
+	}
+}
+
+func TestNodesLimitTheContactsDialedForEachAnswer(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	first := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}
+	conn, _ := makeDiscoveryConn(first)
+	mock.Conns = map[string]net.Conn{first.String(): conn}
+	mock.InjectMessage(network.Packet{Instruction: network.FindNode, Data: network.NodeID{}}.WithOrigin(first))
+	time.Sleep(50 * time.Millisecond)
+	found := make(chan []network.Contact)
+	go func() {
+		found <- n.Lookup(network.NodeID{0x42})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	contacts := make([]network.Contact, 0)
+	for i := 0; i < 10; i++ {
+		contacts = append(contacts, network.Contact{ID: network.NodeID{byte(i + 1)}, Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 1, byte(i)), Port: 4000}})
+	}
+	contacts = append(contacts, network.Contact{ID: network.NodeID{0x42}, Addr: &net.TCPAddr{IP: net.IPv4zero, Port: 4000}})
+	mock.InjectMessage(network.Packet{Instruction: network.Neighbours, Data: contacts}.WithOrigin(first))
+	<-found
+	if len(mock.Connections) != 4 {
+		t.Errorf("Unexpected dials %v, want 4 of the 10 contacts of the answer", mock.Connections)
+	}
+	for _, dialed := range mock.Connections {
+		if dialed.(*net.TCPAddr).IP.IsUnspecified() {
+			t.Errorf("Unexpected dial of undialable contact %s", dialed)
+		}
+	}
+}
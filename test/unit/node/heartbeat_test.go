@@ -0,0 +1,93 @@
+package node_test
+
+import (
+	"net"
+	"testing"
+	"time"
+	"vicoin/internal/account"
+	"vicoin/internal/node"
+	"vicoin/network"
+)
+
+// makeHelloConn returns a net.Conn which negotiated all default capabilities with its remote.
+func makeHelloConn() net.Conn {
+	local, remote := net.Pipe()
+	localKey, _ := network.NewNodeKey()
+	remoteKey, _ := network.NewNodeKey()
+	go network.NewHelloUpgrader(network.NewHello(remoteKey.ID(), &net.TCPAddr{}, "chain"), time.Second).Upgrade(remote, false)
+	conn, _ := network.NewHelloUpgrader(network.NewHello(localKey.ID(), &net.TCPAddr{}, "chain"), time.Second).Upgrade(local, true)
+	return conn
+}
+
+func TestNodesAnswerPingsWithPongs(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	node.NewNode(mock, internal, external)
+	origin := &net.TCPAddr{Port: 4242}
+	mock.InjectMessage(network.Packet{Instruction: network.Ping, Data: node.Heartbeat{Nonce: 7}}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if len(mock.SentMessages) != 1 {
+		t.Fatalf("Unexpected number of sent messages %d, want 1", len(mock.SentMessages))
+	}
+	msg := mock.SentMessages[0].(network.Packet)
+	if msg.Instruction != network.Pong || msg.Data.(node.Heartbeat).Nonce != 7 {
+		t.Errorf("Unexpected reply %v, want pong with nonce 7", msg)
+	}
+}
+
+func TestNodesMeasureLatencyOfPeers(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	origin := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4242}
+	mock.InjectMessage(network.Packet{Instruction: network.ConnAnnouncment, Data: node.Peer{Addr: origin}})
+	n.Ping(origin)
+	ping := mock.SentMessages[0].(network.Packet)
+	time.Sleep(5 * time.Millisecond)
+	mock.InjectMessage(network.Packet{Instruction: network.Pong, Data: ping.Data}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	latency, ok := n.GetLatency(origin)
+	if !ok || latency < 5*time.Millisecond {
+		t.Errorf("Unexpected latency %s, want at least 5ms", latency)
+	}
+	peers := n.GetPeers()
+	if peers[len(peers)-1].Latency != latency {
+		t.Errorf("Unexpected peer latency %s, want %s", peers[len(peers)-1].Latency, latency)
+	}
+}
+
+func TestNodesCloseUnresponsiveConnections(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	responsive, _ := net.Pipe()
+	mock.Conns = map[string]net.Conn{"unresponsive": makeHelloConn(), "legacy": responsive}
+	n, _ := node.NewNode(mock, internal, external)
+	n.StartHeartbeat(5*time.Millisecond, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	n.Close()
+	if len(mock.Disconnected) != 1 {
+		t.Errorf("Unexpected number of disconnections %d, want 1", len(mock.Disconnected))
+	}
+}
+
+func TestNodesForgetTheLivenessOfDroppedPeers(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	origin := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4242}
+	n.Ping(origin)
+	ping := mock.SentMessages[0].(network.Packet)
+	mock.InjectMessage(network.Packet{Instruction: network.Pong, Data: ping.Data}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := n.GetLatency(origin); !ok {
+		t.Fatal("Latency of a connected peer wasn't measured")
+	}
+	mock.Drop(origin)
+	if latency, ok := n.GetLatency(origin); ok {
+		t.Errorf("Unexpected latency %s kept for a dropped peer", latency)
+	}
+}
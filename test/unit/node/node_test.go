@@ -1,16 +1,20 @@
 package node_test
 
 import (
+	"context"
 	"net"
 	"testing"
 	"time"
+	"vicoin/crypto"
 	"vicoin/internal/account"
 	"vicoin/internal/node"
+	"vicoin/internal/registration"
 	"vicoin/network"
 	mocks "vicoin/test/mocks/network"
 )
 
 func NewPolysocketMock(channel chan interface{}) *mocks.MockPolysocket {
+	registration.RegisterStructsWithGob()
 	return &mocks.MockPolysocket{
 		SentMessages:        make([]interface{}, 0),
 		BroadcastedMessages: make([]interface{}, 0),
@@ -19,6 +23,41 @@ func NewPolysocketMock(channel chan interface{}) *mocks.MockPolysocket {
 	}
 }
 
+// connectTo fills the connections of mock with ones to the given ports, returning their addresses.
+func connectTo(mock *mocks.MockPolysocket, ports ...int) []net.Addr {
+	addrs := make([]net.Addr, 0, len(ports))
+	mock.Conns = make(map[string]net.Conn)
+	for _, port := range ports {
+		addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: port}
+		mock.Conns[addr.String()] = mocks.NewMockConn(addr)
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// sentWith returns the packets sent by mock with the given instruction.
+func sentWith(mock *mocks.MockPolysocket, instruction network.Insn) []network.Packet {
+	packets := make([]network.Packet, 0)
+	for _, msg := range mock.SentMessages {
+		if packet, ok := msg.(network.Packet); ok && packet.Instruction == instruction {
+			packets = append(packets, packet)
+		}
+	}
+	return packets
+}
+
+func makeTransaction() account.SignedTransaction {
+	return account.SignedTransaction{ID: "1", From: makeKey(), To: "santa", Amount: 24.12, Signature: "signature"}
+}
+
+// makeKey returns an encoded public key, which senders of transactions must be.
+func makeKey() string {
+	registration.RegisterStructsWithGob()
+	public, _, _ := crypto.KeyGen(512)
+	key, _ := public.ToString()
+	return key
+}
+
 func TestNewNodeReturnsAPointerToANewNode(t *testing.T) {
 	internal := make(chan interface{})
 	external := make(chan account.SignedTransaction)
@@ -63,7 +102,7 @@ func TestNodesSendPeerReplyUponRequest(t *testing.T) {
 	node.NewNode(mock, internal, external)
 	mock.InjectMessage(network.Packet{
 		Instruction: network.PeerRequest,
-		Data:        &net.TCPAddr{},
+		Data:        &net.TCPAddr{Port: 4242},
 	})
 	time.Sleep(50 * time.Millisecond)
 	if len(mock.SentMessages) != 1 {
@@ -75,19 +114,17 @@ func TestNodesSendPeerReplyUponRequest(t *testing.T) {
 	}
 }
 
-func TestNodesBroadcastConnectionAnnouncementUponConnection(t *testing.T) {
+func TestNodesGossipConnectionAnnouncementUponConnection(t *testing.T) {
 	internal := make(chan interface{})
 	external := make(chan account.SignedTransaction)
 	mock := NewPolysocketMock(internal)
+	connectTo(mock, 4242, 4343)
 	node, _ := node.NewNode(mock, internal, external)
 	node.Connect(&net.TCPAddr{}) // Mock address
 	time.Sleep(50 * time.Millisecond)
-	if len(mock.BroadcastedMessages) != 1 {
-		t.Errorf("Unexpected number of sent messages %d, want 1", len(mock.SentMessages))
-	}
-	msg := mock.BroadcastedMessages[0].(network.Packet)
-	if msg.Instruction != network.ConnAnnouncment {
-		t.Errorf("Unexpected instruction %d, want 3", msg.Instruction)
+	announcements := sentWith(mock, network.ConnAnnouncment)
+	if len(announcements) != 2 {
+		t.Errorf("Unexpected number of sent announcements %d, want 2", len(announcements))
 	}
 }
 
@@ -95,16 +132,19 @@ func TestNodesPropagateConnectionAnnouncements(t *testing.T) {
 	internal := make(chan interface{})
 	external := make(chan account.SignedTransaction)
 	mock := NewPolysocketMock(internal)
-	n, _ := node.NewNode(mock, internal, external)
-	n.Connect(&net.TCPAddr{}) // Mock address
-	mock.InjectMessage(network.Packet{Instruction: network.ConnAnnouncment, Data: node.Peer{}})
+	origin := connectTo(mock, 4242, 4343)[0]
+	node.NewNode(mock, internal, external)
+	mock.InjectMessage(network.Packet{Instruction: network.ConnAnnouncment, Data: node.Peer{Addr: &net.TCPAddr{Port: 4444}}, TTL: 2}.WithOrigin(origin))
 	time.Sleep(50 * time.Millisecond)
-	if len(mock.BroadcastedMessages) != 2 {
-		t.Errorf("Unexpected number of sent messages %d, want 1", len(mock.SentMessages))
+	if len(mock.SentMessages) != 1 {
+		t.Fatalf("Unexpected number of sent messages %d, want 1", len(mock.SentMessages))
 	}
-	msg := mock.BroadcastedMessages[1].(network.Packet)
-	if msg.Instruction != network.ConnAnnouncment {
-		t.Errorf("Unexpected instruction %d, want 3", msg.Instruction)
+	msg := mock.SentMessages[0].(network.Packet)
+	if msg.Instruction != network.ConnAnnouncment || msg.TTL != 1 {
+		t.Errorf("Unexpected instruction %d with TTL %d, want 2 with TTL 1", msg.Instruction, msg.TTL)
+	}
+	if mock.SentTo[0] == origin {
+		t.Error("Announcement was sent back to its origin")
 	}
 }
 
@@ -114,7 +154,7 @@ func TestNodesSendSignedTransactionsOnChannel(t *testing.T) {
 	mock := NewPolysocketMock(internal)
 	node, _ := node.NewNode(mock, internal, external)
 	node.Connect(&net.TCPAddr{}) // Mock address
-	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: account.SignedTransaction{}})
+	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: makeTransaction()})
 	time.Sleep(50 * time.Millisecond)
 	<-external
 }
@@ -123,16 +163,247 @@ func TestNodesPropagateTransactions(t *testing.T) {
 	internal := make(chan interface{})
 	external := make(chan account.SignedTransaction)
 	mock := NewPolysocketMock(internal)
-	n, _ := node.NewNode(mock, internal, external)
-	n.Connect(&net.TCPAddr{}) // Mock address
-	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: account.SignedTransaction{}})
+	origin := connectTo(mock, 4242, 4343)[0]
+	node.NewNode(mock, internal, external)
+	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: makeTransaction(), TTL: 2}.WithOrigin(origin))
 	time.Sleep(50 * time.Millisecond)
 	<-external
-	if len(mock.BroadcastedMessages) != 2 {
-		t.Errorf("Unexpected number of sent messages %d, want 1", len(mock.SentMessages))
+	if len(mock.SentMessages) != 1 {
+		t.Fatalf("Unexpected number of sent messages %d, want 1", len(mock.SentMessages))
 	}
-	msg := mock.BroadcastedMessages[1].(network.Packet)
+	msg := mock.SentMessages[0].(network.Packet)
 	if msg.Instruction != network.Transaction {
-		t.Errorf("Unexpected instruction %d, want 4", msg.Instruction)
+		t.Errorf("Unexpected instruction %d, want 3", msg.Instruction)
+	}
+}
+
+func TestNodesStopPropagatingPacketsWithoutTTL(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	origin := connectTo(mock, 4242, 4343)[0]
+	node.NewNode(mock, internal, external)
+	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: makeTransaction(), TTL: 1}.WithOrigin(origin))
+	<-external
+	if len(mock.SentMessages) != 0 {
+		t.Errorf("Unexpected number of sent messages %d, want 0", len(mock.SentMessages))
+	}
+}
+
+func TestNodesAdvertiseConfiguredAddresses(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	advertised := []net.Addr{
+		&net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4000},
+		&net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 4000},
+	}
+	n.SetAdvertisedAddrs(advertised)
+	connectTo(mock, 4242)
+	n.Connect(&net.TCPAddr{}) // Mock address
+	request := mock.SentMessages[0].(network.Packet)
+	if request.Data.(net.Addr) != advertised[0] {
+		t.Errorf("Unexpected address %s in peer request, want %s", request.Data, advertised[0])
+	}
+	announcements := sentWith(mock, network.ConnAnnouncment)
+	if len(announcements) != 2 {
+		t.Fatalf("Unexpected number of announcements %d, want 2", len(announcements))
+	}
+	for i, msg := range announcements {
+		if msg.Data.(node.Peer).Addr != advertised[i] {
+			t.Errorf("Unexpected announced address %s, want %s", msg.Data, advertised[i])
+		}
+	}
+	if len(n.GetPeers()) != 2 {
+		t.Errorf("Unexpected number of peers %d, want 2", len(n.GetPeers()))
+	}
+}
+
+func TestNodesPenalizeAndDisconnectPeersSendingMalformedPackets(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	origin := &net.TCPAddr{Port: 4242}
+	mock.InjectMessage(network.Packet{Instruction: network.PeerRequest, Data: "not an address"}.WithOrigin(origin))
+	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: account.SignedTransaction{Amount: -1}}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if n.GetMisbehaviour(origin) == 0 {
+		t.Error("Malformed packets weren't penalized")
+	}
+	for i := 0; i < 3; i++ { // Reaching the disconnect score.
+		mock.InjectMessage(network.Packet{Instruction: network.ConnAnnouncment, Data: node.Peer{}}.WithOrigin(origin))
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(mock.Disconnected) != 1 || mock.Disconnected[0] != origin {
+		t.Errorf("Unexpected disconnections %v, want %s", mock.Disconnected, origin)
+	}
+	if len(mock.SentMessages) != 0 || len(mock.BroadcastedMessages) != 0 {
+		t.Error("Malformed packets were acted upon")
+	}
+}
+
+func TestNodesRelayTransactionsOnce(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction, 2)
+	mock := NewPolysocketMock(internal)
+	origins := connectTo(mock, 4242, 4343, 4444)
+	node.NewNode(mock, internal, external)
+	transaction := makeTransaction()
+	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: transaction, TTL: 2}.WithOrigin(origins[0]))
+	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: transaction, TTL: 2}.WithOrigin(origins[1]))
+	time.Sleep(50 * time.Millisecond)
+	if len(mock.SentMessages) != 2 || len(external) != 1 {
+		t.Errorf("Unexpected number of relays %d and deliveries %d, want 2 and 1", len(mock.SentMessages), len(external))
+	}
+}
+
+func TestNodesDontRelayTheirOwnTransactions(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction, 1)
+	mock := NewPolysocketMock(internal)
+	origin := connectTo(mock, 4242)[0]
+	n, _ := node.NewNode(mock, internal, external)
+	transaction := makeTransaction()
+	n.SendTransaction(transaction)
+	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: transaction, TTL: 2}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if len(mock.SentMessages) != 1 || len(external) != 0 {
+		t.Errorf("Own transaction was relayed again")
+	}
+}
+
+func TestNodesFetchAnnouncedTransactions(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction, 1)
+	mock := NewPolysocketMock(internal)
+	origin := connectTo(mock, 4242)[0]
+	node.NewNode(mock, internal, external)
+	message := network.Packet{Instruction: network.Transaction, Data: makeTransaction(), TTL: 1}
+	id, _ := network.NewMessageID(message)
+	mock.InjectMessage(network.Packet{Instruction: network.Inv, Data: []network.MessageID{id}}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if requests := sentWith(mock, network.GetData); len(requests) != 1 {
+		t.Fatalf("Unexpected number of requests %d, want 1", len(requests))
+	}
+	mock.InjectMessage(network.Packet{Instruction: network.Data, Data: message}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if len(external) != 1 {
+		t.Error("Requested transaction wasn't delivered")
+	}
+}
+
+func TestNodesPenalizePeersFloodingThem(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	origin := &net.TCPAddr{Port: 4242}
+	for i := 0; i < 10; i++ {
+		mock.InjectMessage(network.Packet{Instruction: network.Ping, Data: node.Heartbeat{Nonce: uint64(i)}}.WithOrigin(origin))
+	}
+	time.Sleep(50 * time.Millisecond)
+	if pongs := sentWith(mock, network.Pong); len(pongs) != 5 {
+		t.Errorf("Unexpected number of pongs %d, want 5", len(pongs))
+	}
+	if n.GetMisbehaviour(origin) == 0 {
+		t.Error("Flooding wasn't penalized")
+	}
+}
+
+func TestNodesAnswerRequestsThroughTheirRPC(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	n.RPC().Handle("echo", func(ctx context.Context, from net.Addr, body interface{}) (interface{}, error) {
+		return body, nil
+	})
+	origin := &net.TCPAddr{Port: 4242}
+	mock.InjectMessage(network.Packet{Instruction: network.Request, Data: network.RPCRequest{ID: 7, Method: "echo", Body: "lorem ipsum"}}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	responses := sentWith(mock, network.Response)
+	if len(responses) != 1 {
+		t.Fatalf("Unexpected number of responses %d, want 1", len(responses))
+	}
+	response := responses[0].Data.(network.RPCResponse)
+	if response.ID != 7 || response.Body != "lorem ipsum" {
+		t.Errorf("Unexpected response %v, want echo with ID 7", response)
+	}
+}
+
+func TestNodesRefuseTransactionsExpiredLongAgo(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction, 1)
+	mock := NewPolysocketMock(internal)
+	origins := connectTo(mock, 4242, 4343)
+	node.NewNode(mock, internal, external)
+	transaction := makeTransaction()
+	transaction.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: transaction, TTL: 2}.WithOrigin(origins[0]))
+	time.Sleep(50 * time.Millisecond)
+	if len(mock.SentMessages) != 0 || len(external) != 0 {
+		t.Error("Transaction expired an hour ago was relayed or delivered")
+	}
+}
+
+func TestNodesRefuseTransactionsFromSendersWhichArentKeys(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction, 1)
+	mock := NewPolysocketMock(internal)
+	origin := connectTo(mock, 4242)[0]
+	n, _ := node.NewNode(mock, internal, external)
+	transaction := makeTransaction()
+	transaction.From = "AAAA"
+	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: transaction, TTL: 2}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if len(external) != 0 || n.GetMisbehaviour(origin) == 0 {
+		t.Error("Transaction from a sender which isn't a key was accepted")
+	}
+}
+
+func TestNodesRefuseMultisigTransactionsFromAccountsTheirPolicyDoesntControl(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction, 1)
+	mock := NewPolysocketMock(internal)
+	origin := connectTo(mock, 4242)[0]
+	n, _ := node.NewNode(mock, internal, external)
+	policy := account.MultisigPolicy{Threshold: 1, Keys: []string{makeKey(), makeKey()}}
+	transaction := account.SignedTransaction{ID: "1", From: "claus", To: "santa", Amount: 24.12, Multisig: &policy, Signatures: []string{"signature", ""}}
+	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: transaction, TTL: 2}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if len(external) != 0 || n.GetMisbehaviour(origin) == 0 {
+		t.Error("Multisig transaction from an account its policy doesn't control was accepted")
+	}
+	transaction.ID, transaction.From = "2", policy.Account()
+	mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: transaction, TTL: 2}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if len(external) != 1 {
+		t.Error("Multisig transaction from the account of its policy wasn't delivered")
+	}
+}
+
+func TestNodesBanMisbehavingHostsUnlessOnLoopback(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	remote := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4242}
+	local := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 4242}
+	for _, origin := range []net.Addr{remote, local} {
+		for i := 0; i < 5; i++ {
+			mock.InjectMessage(network.Packet{Instruction: network.ConnAnnouncment, Data: node.Peer{}}.WithOrigin(origin))
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(mock.Disconnected) != 2 {
+		t.Errorf("Unexpected disconnections %v, want %s and %s", mock.Disconnected, remote, local)
+	}
+	if !n.GetPeerBook().Banned(remote) || n.GetPeerBook().Banned(local) {
+		t.Errorf("Unexpected bans of %s and %s, want only %s", remote, local, remote)
+	}
+	if n.GetMisbehaviour(remote) != 0 || n.GetPeerBook().Misbehaviour(remote) != 0 {
+		t.Error("Misbehaviour acted upon wasn't forgiven")
 	}
 }
@@ -0,0 +1,157 @@
+package node_test
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+	"vicoin/internal/account"
+	"vicoin/internal/node"
+	"vicoin/network"
+)
+
+func TestPeerBooksSurviveRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.gob")
+	book, err := node.NewPeerBook(path)
+	if err != nil {
+		t.Fatal("Error when creating peer book: ", err)
+	}
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}
+	book.Succeeded(addr)
+	book.Failed(addr)
+	book.Penalize(addr, 5)
+	book.Ban(&net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4000}, 0)
+	if err := book.Save(); err != nil {
+		t.Fatal("Error when saving peer book: ", err)
+	}
+	restored, err := node.NewPeerBook(path)
+	if err != nil {
+		t.Fatal("Error when loading peer book: ", err)
+	}
+	record, ok := restored.Get(addr)
+	if !ok || record.Successes != 1 || record.Failures != 1 || record.Misbehaviour != 5 || record.LastSeen.IsZero() {
+		t.Errorf("Unexpected restored record %v", record)
+	}
+	if !restored.Banned(&net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 5000}) {
+		t.Error("Permanent ban wasn't restored")
+	}
+}
+
+func TestPeerBooksLiftTemporaryBans(t *testing.T) {
+	book, _ := node.NewPeerBook("")
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}
+	book.Ban(addr, 20*time.Millisecond)
+	if !book.Banned(addr) {
+		t.Error("Address wasn't banned")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if book.Banned(addr) {
+		t.Error("Temporary ban wasn't lifted")
+	}
+}
+
+func TestPeerBooksPreferVerifiedPeers(t *testing.T) {
+	book, _ := node.NewPeerBook("")
+	unverified := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}
+	verified := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4000}
+	banned := &net.TCPAddr{IP: net.ParseIP("10.0.0.3"), Port: 4000}
+	book.Add(unverified)
+	book.Succeeded(verified)
+	book.Succeeded(banned)
+	book.Ban(banned, time.Hour)
+	candidates := book.Candidates(10)
+	if len(candidates) != 2 || candidates[0].String() != verified.String() {
+		t.Errorf("Unexpected candidates %v, want %s first and no banned ones", candidates, verified)
+	}
+}
+
+func TestNodesBanMisbehavingPeers(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	origin := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4242}
+	for i := 0; i < 5; i++ {
+		mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: account.SignedTransaction{Amount: -1}}.WithOrigin(origin))
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := n.Connect(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}); err == nil {
+		t.Error("Banned peer was connected to")
+	}
+	if len(mock.Connections) != 0 {
+		t.Errorf("Unexpected number of dials %d, want 0", len(mock.Connections))
+	}
+}
+
+func TestNodesBanMisbehavingHostsReconnectingFromOtherPorts(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	for port := 4242; port < 4247; port++ {
+		origin := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: port}
+		mock.InjectMessage(network.Packet{Instruction: network.Transaction, Data: account.SignedTransaction{Amount: -1}}.WithOrigin(origin))
+	}
+	time.Sleep(50 * time.Millisecond)
+	if !n.GetPeerBook().Banned(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}) {
+		t.Error("Host misbehaving from several ports wasn't banned")
+	}
+}
+
+func TestNodesBootstrapFromKnownPeersAndSeeds(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	known := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}
+	seed := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4000}
+	n.GetPeerBook().Succeeded(known)
+	if connected := n.Bootstrap([]net.Addr{seed}); connected != 2 {
+		t.Errorf("Unexpected number of bootstrap peers %d, want 2", connected)
+	}
+	if len(mock.Connections) != 2 || mock.Connections[0].String() != known.String() {
+		t.Errorf("Unexpected dials %v, want %s first", mock.Connections, known)
+	}
+}
+
+func TestPeerBooksKeepMisbehaviourByHostWithoutRecordingOrigins(t *testing.T) {
+	book, _ := node.NewPeerBook("")
+	book.Penalize(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 50000}, 5)
+	if score := book.Penalize(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 50001}, 5); score != 10 {
+		t.Errorf("Unexpected score %d from another port, want 10", score)
+	}
+	book.Add(&net.TCPAddr{IP: net.IPv4zero, Port: 4000})
+	book.Add(&net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 0})
+	if book.Len() != 0 {
+		t.Errorf("Unexpected %d records of origins and undialable addresses, want 0", book.Len())
+	}
+	listening := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4000}
+	book.Add(listening)
+	if record, ok := book.Get(listening); !ok || record.Misbehaviour != 10 {
+		t.Errorf("Unexpected record %v, want the misbehaviour of its host", record)
+	}
+}
+
+func TestPeerBooksEvictTheLeastWorthDialingOnceFull(t *testing.T) {
+	book, _ := node.NewPeerBook("")
+	verified := &net.TCPAddr{IP: net.ParseIP("10.1.0.0"), Port: 4000}
+	book.Succeeded(verified)
+	for i := 0; book.Len() < 10000; i++ {
+		book.Add(&net.TCPAddr{IP: net.IPv4(10, 2, byte(i/256), byte(i%256)), Port: 4000})
+	}
+	book.Add(&net.TCPAddr{IP: net.ParseIP("10.3.0.0"), Port: 4000})
+	if book.Len() != 10000 {
+		t.Errorf("Unexpected %d records, want 10000", book.Len())
+	}
+	if _, ok := book.Get(verified); !ok {
+		t.Error("Verified record was evicted")
+	}
+}
+
+func TestPeerBooksTellNilAddressesArentBanned(t *testing.T) {
+	book, _ := node.NewPeerBook("")
+	var addr *net.TCPAddr
+	if book.Banned(addr) || book.Banned(nil) {
+		t.Error("Unexpected ban of a nil address")
+	}
+}
@@ -0,0 +1,147 @@
+package node_test
+
+import (
+	"net"
+	"testing"
+	"time"
+	"vicoin/internal/account"
+	"vicoin/internal/node"
+	"vicoin/network"
+	mocks "vicoin/test/mocks/network"
+)
+
+func TestNodesVerifyAnnouncedAddressesBeforePromotingThem(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	ownKey, _ := network.NewNodeKey()
+	n.SetNodeKey(ownKey)
+	key, _ := network.NewNodeKey()
+	announced := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4000}
+	origin := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51234}
+	mock.Conns = map[string]net.Conn{origin.String(): mocks.NewMockConn(origin)}
+	mock.InjectMessage(network.Packet{Instruction: network.PexRequest, Data: network.NewAnnouncement(key, []net.Addr{announced})}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if len(mock.Connections) != 1 || mock.Connections[0].String() != announced.String() {
+		t.Errorf("Unexpected dials %v, want one to %s", mock.Connections, announced)
+	}
+	if !contains(n.GetPeers(), announced) {
+		t.Error("Reachable address wasn't promoted to a peer")
+	}
+	replies := sentWith(mock, network.PexReply)
+	if len(replies) != 1 || replies[0].Data.(node.PexReply).Self.Verify() != nil {
+		t.Error("Peer exchange request wasn't answered with a valid announcement")
+	}
+}
+
+func TestNodesRefuseForgedAnnouncements(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	key, _ := network.NewNodeKey()
+	announcement := network.NewAnnouncement(key, []net.Addr{&net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4000}})
+	announcement.Addrs[0] = "198.51.100.7:4000"
+	origin := &net.TCPAddr{Port: 4242}
+	mock.InjectMessage(network.Packet{Instruction: network.PexRequest, Data: announcement}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if len(mock.Connections) != 0 || n.GetMisbehaviour(origin) == 0 {
+		t.Error("Forged announcement was acted upon")
+	}
+}
+
+func TestNodesRefuseAnnouncementsFromUnknownConnections(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	key, _ := network.NewNodeKey()
+	origin := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51234}
+	mock.InjectMessage(network.Packet{Instruction: network.PexRequest, Data: network.NewAnnouncement(key, []net.Addr{&net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 4000}})}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if len(mock.Connections) != 0 || n.GetMisbehaviour(origin) == 0 {
+		t.Errorf("Unexpected dials %v for an announcement over an unknown connection, want none", mock.Connections)
+	}
+}
+
+func TestNodesLimitTheAddressesDialedForEachPeer(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	origin := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51234}
+	peers := []node.Peer{{Addr: &net.TCPAddr{IP: net.IPv4zero, Port: 4000}}}
+	for i := 1; i <= 20; i++ {
+		peers = append(peers, node.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, byte(i)), Port: 4000}})
+	}
+	mock.InjectMessage(network.Packet{Instruction: network.PeerReply, Data: peers}.WithOrigin(origin))
+	time.Sleep(50 * time.Millisecond)
+	if len(mock.Connections) == 0 || len(mock.Connections) > 8 {
+		t.Errorf("Unexpected number of dials %d, want 1 to 8", len(mock.Connections))
+	}
+	for _, dialed := range mock.Connections {
+		if dialed.(*net.TCPAddr).IP.IsUnspecified() {
+			t.Errorf("Unexpected dial of %s", dialed)
+		}
+	}
+	if n.GetPeerBook().Len() != 20 {
+		t.Errorf("Unexpected %d addresses in the peer book, want 20", n.GetPeerBook().Len())
+	}
+}
+
+func TestNodesShareASampleOfPeersWithoutThemselves(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	node.NewNode(mock, internal, external)
+	for i := 1; i <= 40; i++ {
+		peer := node.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, byte(i)), Port: 4000}}
+		mock.InjectMessage(network.Packet{Instruction: network.ConnAnnouncment, Data: peer, TTL: 1})
+		time.Sleep(time.Millisecond) // Leaving room to verify each.
+	}
+	time.Sleep(50 * time.Millisecond)
+	mock.InjectMessage(network.Packet{Instruction: network.PeerRequest, Data: &net.TCPAddr{Port: 4242}})
+	time.Sleep(50 * time.Millisecond)
+	reply := sentWith(mock, network.PeerReply)[0].Data.([]node.Peer)
+	if len(reply) != 32 {
+		t.Errorf("Unexpected number of shared peers %d, want 32", len(reply))
+	}
+	if contains(reply, mock.GetAddr()) {
+		t.Error("Own address was shared")
+	}
+}
+
+func contains(peers []node.Peer, addr net.Addr) bool {
+	for _, peer := range peers {
+		if peer.Addr.String() == addr.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNodesStrengthenTheNetworkWithoutDialingBannedPeers(t *testing.T) {
+	internal := make(chan interface{})
+	external := make(chan account.SignedTransaction)
+	mock := NewPolysocketMock(internal)
+	n, _ := node.NewNode(mock, internal, external)
+	for i := 1; i <= 3; i++ {
+		peer := node.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, byte(i)), Port: 4000}}
+		mock.InjectMessage(network.Packet{Instruction: network.ConnAnnouncment, Data: peer, TTL: 1})
+	}
+	time.Sleep(50 * time.Millisecond)
+	banned := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 4000}
+	n.GetPeerBook().Ban(banned, time.Hour)
+	mock.Connections = nil
+	mock.InjectMessage(network.Packet{Instruction: network.PeerReply, Data: []node.Peer{}})
+	time.Sleep(50 * time.Millisecond)
+	if len(mock.Connections) != 2 {
+		t.Errorf("Unexpected dials %v, want the 2 peers not banned", mock.Connections)
+	}
+	for _, dialed := range mock.Connections {
+		if dialed.String() == banned.String() {
+			t.Errorf("Unexpected dial of banned peer %s", dialed)
+		}
+	}
+}